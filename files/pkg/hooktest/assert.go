@@ -0,0 +1,107 @@
+package hooktest
+
+import (
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type HookFunc[I, O any] func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)
+
+func NewOperationBody[I, O any](input I, hook ...types.MiddlewareHook) *types.OperationBody[I, O] {
+	body := &types.OperationBody[I, O]{Input: input}
+	if len(hook) > 0 {
+		body.Hook = hook[0]
+	}
+	return body
+}
+
+// Run 执行钩子或函数，钩子返回 nil 时与服务端一致地视为原样返回入参
+func Run[I, O any](h *Harness, hookFunc HookFunc[I, O], body *types.OperationBody[I, O]) (*types.OperationBody[I, O], error) {
+	out, err := hookFunc(h.Request, body)
+	if err == nil && out == nil {
+		out = body
+	}
+	return out, err
+}
+
+func AssertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func AssertErrorContains(t testing.TB, err error, substr string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error containing [%s] but got nil", substr)
+	}
+	if !strings.Contains(err.Error(), substr) {
+		t.Fatalf("expected error containing [%s] but got [%s]", substr, err.Error())
+	}
+}
+
+func AssertInput[I, O any](t testing.TB, body *types.OperationBody[I, O], expected I) {
+	t.Helper()
+	if body == nil {
+		t.Fatalf("operation body is nil")
+	}
+	assertEqual(t, "input", body.Input, expected)
+}
+
+func AssertResponseData[I, O any](t testing.TB, body *types.OperationBody[I, O], expected O) {
+	t.Helper()
+	if body == nil || body.Response == nil {
+		t.Fatalf("operation response is nil")
+	}
+	assertEqual(t, "response data", body.Response.Data, expected)
+}
+
+func AssertResponseErrors[I, O any](t testing.TB, body *types.OperationBody[I, O], messages ...string) {
+	t.Helper()
+	if body == nil || body.Response == nil {
+		t.Fatalf("operation response is nil")
+	}
+	actual := make([]string, 0, len(body.Response.Errors))
+	for _, item := range body.Response.Errors {
+		actual = append(actual, item.Message)
+	}
+	assertEqual(t, "response errors", actual, append([]string{}, messages...))
+}
+
+func AssertCanceled[I, O any](t testing.TB, body *types.OperationBody[I, O], canceled bool) {
+	t.Helper()
+	if body == nil {
+		t.Fatalf("operation body is nil")
+	}
+	if body.Canceled != canceled {
+		t.Fatalf("expected canceled [%v] but got [%v]", canceled, body.Canceled)
+	}
+}
+
+func AssertCalled(t testing.TB, h *Harness, path string, times int) {
+	t.Helper()
+	if actual := len(h.Calls(path)); actual != times {
+		t.Fatalf("expected operation [%s] called %d time(s) but got %d", path, times, actual)
+	}
+}
+
+func AssertLogContains(t testing.TB, h *Harness, substr string) {
+	t.Helper()
+	if !strings.Contains(h.Logs(), substr) {
+		t.Fatalf("expected logs containing [%s] but got:\n%s", substr, h.Logs())
+	}
+}
+
+func assertEqual(t testing.TB, name string, actual, expected any) {
+	t.Helper()
+	if reflect.DeepEqual(actual, expected) {
+		return
+	}
+	actualBytes, _ := utils.MarshalWithoutEscapeHTML(actual)
+	expectedBytes, _ := utils.MarshalWithoutEscapeHTML(expected)
+	t.Fatalf("%s mismatch\nexpected: %s\nactual:   %s", name, strings.TrimSpace(string(expectedBytes)), strings.TrimSpace(string(actualBytes)))
+}
@@ -0,0 +1,166 @@
+package hooktest
+
+import (
+	"bytes"
+	"context"
+	"custom-go/pkg/plugins"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+type (
+	Harness struct {
+		Request  *types.HookRequest
+		Recorder *httptest.ResponseRecorder
+		logs     *bytes.Buffer
+		executor *plugins.MemoryOperationExecutor
+		calls    *callRecorder
+	}
+	Option        func(*Harness)
+	OperationFunc func(input json.RawMessage) (data any, err error)
+	callRecorder  struct {
+		calls map[string][]json.RawMessage
+		mutex sync.Mutex
+	}
+)
+
+// New 构造一个无需启动 echo 服务和 Fireboom 节点的 HookRequest，内部调用全部由 MemoryOperationExecutor 响应
+func New(options ...Option) *Harness {
+	e := echo.New()
+	logs := &bytes.Buffer{}
+	e.Logger.SetOutput(logs)
+	e.Logger.SetLevel(log.DEBUG)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	wg := &types.BaseRequestBodyWg{
+		ClientRequest: &types.WunderGraphRequest{
+			Method:     http.MethodPost,
+			RequestURI: "/",
+			Headers:    types.RequestHeaders{},
+		},
+	}
+	executor := plugins.NewMemoryOperationExecutor()
+	calls := &callRecorder{calls: make(map[string][]json.RawMessage)}
+	client := types.InternalClientFactoryCall(types.RequestHeaders{}, wg)
+	client.Executor = plugins.NewRecordingOperationExecutor(executor, calls)
+	h := &Harness{
+		Request:  &types.HookRequest{Context: e.NewContext(req, recorder), InternalClient: client},
+		Recorder: recorder,
+		logs:     logs,
+		executor: executor,
+		calls:    calls,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+func WithUser(user *types.User) Option {
+	return func(h *Harness) {
+		h.Request.User = user
+	}
+}
+
+// WithHeaders 同时设置客户端原始请求头和当前钩子请求的请求头
+func WithHeaders(headers types.RequestHeaders) Option {
+	return func(h *Harness) {
+		for k, v := range headers {
+			h.Request.ClientRequest.Headers[k] = v
+			h.Request.Request().Header.Set(k, v)
+		}
+	}
+}
+
+func WithClientRequest(clientRequest *types.WunderGraphRequest) Option {
+	return func(h *Harness) {
+		if clientRequest.Headers == nil {
+			clientRequest.Headers = types.RequestHeaders{}
+		}
+		h.Request.ClientRequest = clientRequest
+	}
+}
+
+func WithExtraHeaders(headers types.RequestHeaders) Option {
+	return func(h *Harness) {
+		h.Request.InternalClient.WithHeaders(headers)
+	}
+}
+
+// StubOperation 为内部调用的 operation 返回固定数据和错误
+func (h *Harness) StubOperation(path string, data any, errs ...types.RequestError) *Harness {
+	return h.StubOperationFunc(path, func(json.RawMessage) (any, error) {
+		if len(errs) > 0 {
+			return types.OperationBodyResponse[any]{Data: data, Errors: errs}, nil
+		}
+		return data, nil
+	})
+}
+
+// StubOperationFunc 根据入参计算 operation 的返回，返回 types.OperationBodyResponse 时可同时携带 errors
+func (h *Harness) StubOperationFunc(path string, operationFunc OperationFunc) *Harness {
+	h.executor.HandleOperation(path, func(_ context.Context, _ *types.InternalClient, input json.RawMessage) (any, error) {
+		return operationFunc(input)
+	})
+	return h
+}
+
+// StubSubscription 为订阅依次推送 events，推送完成后关闭通道
+func (h *Harness) StubSubscription(path string, events ...any) *Harness {
+	h.executor.HandleSubscription(path, func(_ context.Context, _ *types.InternalClient, _ json.RawMessage, emit func(any) error) error {
+		for _, event := range events {
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return h
+}
+
+// StubUpload 为 UploadClient 的上传返回固定结果，需通过 UploadParameter.Client 传入 Request.InternalClient
+func (h *Harness) StubUpload(provider string, files types.UploadedFiles) *Harness {
+	h.executor.HandleUpload(provider, func(context.Context, *types.InternalClient, *types.UploadRequest) (types.UploadedFiles, error) {
+		return files, nil
+	})
+	return h
+}
+
+// Executor 用于以 plugins.HandleMemoryOperation 等注册带类型的处理函数
+func (h *Harness) Executor() *plugins.MemoryOperationExecutor {
+	return h.executor
+}
+
+// Calls 返回指定 operation 被调用时的入参
+func (h *Harness) Calls(path string) []json.RawMessage {
+	h.calls.mutex.Lock()
+	defer h.calls.mutex.Unlock()
+	return append([]json.RawMessage(nil), h.calls.calls[path]...)
+}
+
+func (h *Harness) Logs() string {
+	return h.logs.String()
+}
+
+func (r *callRecorder) record(path string, input any) {
+	inputBytes, _ := utils.MarshalWithoutEscapeHTML(input)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls[path] = append(r.calls[path], inputBytes)
+}
+
+func (r *callRecorder) RecordExecute(path string, input any, _ []byte, _ error) {
+	r.record(path, input)
+}
+
+func (r *callRecorder) RecordSubscribe(path string, input any) func([]byte) {
+	r.record(path, input)
+	return func([]byte) {}
+}
@@ -0,0 +1,118 @@
+package hooktest
+
+import (
+	"custom-go/pkg/plugins"
+	"custom-go/pkg/types"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type (
+	testUserInput struct {
+		Id string `json:"id"`
+	}
+	testUserOutput struct {
+		Name string `json:"name"`
+	}
+)
+
+var testGetUser = &plugins.Meta[testUserInput, testUserOutput]{Path: "User/Get", Type: types.OperationType_QUERY}
+
+func testLoadUserHook(hook *types.HookRequest, body *types.OperationBody[testUserInput, testUserOutput]) (*types.OperationBody[testUserInput, testUserOutput], error) {
+	user, err := testGetUser.Execute(body.Input, hook.InternalClient)
+	if err != nil {
+		return nil, err
+	}
+	if user.Name == "" {
+		return nil, nil
+	}
+	hook.Logger().Infof("user [%s] loaded", body.Input.Id)
+	body.ResetResponse(user)
+	return body, nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		stub        func(*Harness)
+		expected    *testUserOutput
+		errSubstr   string
+		passthrough bool
+	}{
+		{
+			name:     "stubbed data",
+			stub:     func(h *Harness) { h.StubOperation(testGetUser.Path, testUserOutput{Name: "alice"}) },
+			expected: &testUserOutput{Name: "alice"},
+		},
+		{
+			name:        "nil result passes input through",
+			stub:        func(h *Harness) { h.StubOperation(testGetUser.Path, testUserOutput{}) },
+			passthrough: true,
+		},
+		{
+			name:      "stubbed graphql errors",
+			stub:      func(h *Harness) { h.StubOperation(testGetUser.Path, nil, types.RequestError{Message: "not found"}) },
+			errSubstr: "not found",
+		},
+		{
+			name: "stubbed func error",
+			stub: func(h *Harness) {
+				h.StubOperationFunc(testGetUser.Path, func(json.RawMessage) (any, error) { return nil, errors.New("node down") })
+			},
+			errSubstr: "node down",
+		},
+		{
+			name:      "operation not stubbed",
+			stub:      func(*Harness) {},
+			errSubstr: "is not registered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(WithUser(&types.User{UserId: "1"}))
+			tt.stub(h)
+			body := NewOperationBody[testUserInput, testUserOutput](testUserInput{Id: "1"}, types.MiddlewareHook_mutatingPostResolve)
+			out, err := Run[testUserInput, testUserOutput](h, testLoadUserHook, body)
+			if tt.errSubstr != "" {
+				AssertErrorContains(t, err, tt.errSubstr)
+				return
+			}
+			AssertNoError(t, err)
+			AssertCalled(t, h, testGetUser.Path, 1)
+			AssertInput(t, out, testUserInput{Id: "1"})
+			if tt.passthrough {
+				if out != body || out.Response != nil {
+					t.Fatalf("expected input body passed through")
+				}
+				return
+			}
+			AssertResponseData(t, out, *tt.expected)
+			AssertLogContains(t, h, "user [1] loaded")
+		})
+	}
+}
+
+func TestHarnessCalls(t *testing.T) {
+	h := New().StubOperation(testGetUser.Path, testUserOutput{Name: "bob"})
+	for _, id := range []string{"1", "2"} {
+		_, err := testGetUser.Execute(testUserInput{Id: id}, h.Request.InternalClient)
+		AssertNoError(t, err)
+	}
+
+	calls := h.Calls(testGetUser.Path)
+	assertEqual(t, "calls", len(calls), 2)
+	assertEqual(t, "second call", strings.TrimSpace(string(calls[1])), `{"id":"2"}`)
+	AssertCalled(t, h, "User/Other", 0)
+}
+
+func TestHarnessOptions(t *testing.T) {
+	user := &types.User{UserId: "1", Roles: []string{"admin"}}
+	h := New(WithUser(user), WithHeaders(types.RequestHeaders{"X-Tenant": "t1"}), WithExtraHeaders(types.RequestHeaders{"X-Trace": "abc"}))
+
+	assertEqual(t, "user", h.Request.User, user)
+	assertEqual(t, "client request header", h.Request.ClientRequest.Headers["X-Tenant"], "t1")
+	assertEqual(t, "request header", h.Request.Request().Header.Get("X-Tenant"), "t1")
+	assertEqual(t, "extra header", h.Request.InternalClient.ExtraHeaders.Get("X-Trace"), "abc")
+}
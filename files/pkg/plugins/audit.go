@@ -0,0 +1,317 @@
+package plugins
+
+import (
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"github.com/tidwall/gjson"
+	"golang.org/x/exp/slices"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type AuditTarget string
+
+const (
+	AuditTarget_query        AuditTarget = "query"
+	AuditTarget_mutation     AuditTarget = "mutation"
+	AuditTarget_subscription AuditTarget = "subscription"
+	AuditTarget_function     AuditTarget = "function"
+	AuditTarget_proxy        AuditTarget = "proxy"
+	// AuditTarget_impersonation InternalClient.AsUser/AsService 的身份切换及派生客户端的每次内部调用
+	AuditTarget_impersonation AuditTarget = "impersonation"
+)
+
+const (
+	auditRedactedValue          = "******"
+	auditDefaultMaxSummaryBytes = 1024
+	auditRecordBufferSize       = 1024
+	auditFileTimeLayout         = "20060102150405.000"
+)
+
+type (
+	AuditConfiguration struct {
+		// 开启审计的操作类型，为空时不记录
+		Targets []AuditTarget
+		// 需要脱敏的字段名，忽略大小写，匹配任意层级
+		RedactFields []string
+		// 响应摘要的最大字节数，默认 1024
+		MaxSummaryBytes int
+		Sinks           []AuditSink
+	}
+	AuditUser struct {
+		UserId   string   `json:"userId,omitempty"`
+		Name     string   `json:"name,omitempty"`
+		Provider string   `json:"provider,omitempty"`
+		Roles    []string `json:"roles,omitempty"`
+	}
+	AuditRecord struct {
+		Time      time.Time            `json:"time"`
+		Target    AuditTarget          `json:"target"`
+		Path      string               `json:"path"`
+		Hook      types.MiddlewareHook `json:"hook"`
+		RequestId string               `json:"requestId,omitempty"`
+		User      *AuditUser           `json:"user,omitempty"`
+		// 通过 InternalClient.AsUser/AsService 切换后的身份
+		Impersonated *AuditUser      `json:"impersonated,omitempty"`
+		ClientIp     string          `json:"clientIp,omitempty"`
+		Input        json.RawMessage `json:"input,omitempty"`
+		Response     string          `json:"response,omitempty"`
+		Error        string          `json:"error,omitempty"`
+		DurationMs   int64           `json:"durationMs"`
+	}
+	AuditSink interface {
+		Write(*AuditRecord) error
+	}
+	AuditSinkFunc func(*AuditRecord) error
+)
+
+func (f AuditSinkFunc) Write(record *AuditRecord) error {
+	return f(record)
+}
+
+var (
+	auditConfig      AuditConfiguration
+	auditRecordChan  chan *AuditRecord
+	auditWorkerOnce  sync.Once
+	auditConfigMutex sync.RWMutex
+)
+
+func ConfigureAudit(config AuditConfiguration) {
+	auditConfigMutex.Lock()
+	defer auditConfigMutex.Unlock()
+	if config.MaxSummaryBytes <= 0 {
+		config.MaxSummaryBytes = auditDefaultMaxSummaryBytes
+	}
+	auditConfig = config
+}
+
+func AddAuditSink(sink AuditSink) {
+	auditConfigMutex.Lock()
+	defer auditConfigMutex.Unlock()
+	auditConfig.Sinks = append(auditConfig.Sinks, sink)
+}
+
+func auditEnabled(target AuditTarget) bool {
+	auditConfigMutex.RLock()
+	defer auditConfigMutex.RUnlock()
+	return len(auditConfig.Sinks) > 0 && slices.Contains(auditConfig.Targets, target)
+}
+
+// newAuditRecord 在目标未开启审计时返回 nil，finish 对 nil 记录无操作
+func newAuditRecord(brc *types.BaseRequestContext, target AuditTarget, path string, hook types.MiddlewareHook, input []byte) *AuditRecord {
+	if !auditEnabled(target) {
+		return nil
+	}
+
+	record := &AuditRecord{
+		Time:   time.Now(),
+		Target: target,
+		Path:   path,
+		Hook:   hook,
+		Input:  redactAuditJson(input),
+	}
+	if brc == nil {
+		return record
+	}
+	if brc.InternalClient != nil {
+		record.RequestId = brc.ExtraHeaders.Get(string(types.InternalHeader_X_Request_Id))
+		if brc.BaseRequestBodyWg != nil {
+			record.User = newAuditUser(brc.User)
+		}
+	}
+	record.ClientIp = auditClientIp(brc)
+	return record
+}
+
+func newAuditUser(user *types.User) *AuditUser {
+	if user == nil {
+		return nil
+	}
+	return &AuditUser{
+		UserId:   user.UserId,
+		Name:     user.Name,
+		Provider: user.Provider,
+		Roles:    user.Roles,
+	}
+}
+
+func (r *AuditRecord) finish(response []byte, err error) {
+	if r == nil {
+		return
+	}
+
+	r.DurationMs = time.Since(r.Time).Milliseconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if len(response) > 0 {
+		auditConfigMutex.RLock()
+		maxSummaryBytes := auditConfig.MaxSummaryBytes
+		auditConfigMutex.RUnlock()
+		summary := string(redactAuditJson(response))
+		if maxSummaryBytes > 0 && len(summary) > maxSummaryBytes {
+			summary = summary[:maxSummaryBytes] + "..."
+		}
+		r.Response = summary
+	}
+
+	auditWorkerOnce.Do(startAuditWorker)
+	select {
+	case auditRecordChan <- r:
+	default:
+		log.Warnf("audit buffer is full, record dropped [%s/%s]", r.Path, r.Hook)
+	}
+}
+
+func startAuditWorker() {
+	auditRecordChan = make(chan *AuditRecord, auditRecordBufferSize)
+	go func() {
+		for record := range auditRecordChan {
+			auditConfigMutex.RLock()
+			sinks := auditConfig.Sinks
+			auditConfigMutex.RUnlock()
+			for _, sink := range sinks {
+				if err := sink.Write(record); err != nil {
+					log.Errorf("write audit record failed, err: %v", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func auditClientIp(brc *types.BaseRequestContext) string {
+	if brc.InternalClient != nil && brc.BaseRequestBodyWg != nil {
+		if ip := brc.ClientRequest.ClientIP(); ip != "" {
+			return ip
+		}
+	}
+	if brc.Context == nil {
+		return ""
+	}
+	return brc.RealIP()
+}
+
+func redactAuditJson(data []byte) json.RawMessage {
+	if len(data) == 0 || !gjson.ValidBytes(data) {
+		return nil
+	}
+
+	auditConfigMutex.RLock()
+	fields := auditConfig.RedactFields
+	auditConfigMutex.RUnlock()
+	return utils.RedactJson(data, fields, auditRedactedValue)
+}
+
+type auditFileSink struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mutex      sync.Mutex
+}
+
+// NewAuditFileSink 以 JSONL 格式写入审计记录，文件超过 maxSize 字节后轮转，最多保留 maxBackups 个历史文件
+func NewAuditFileSink(filename string, maxSize int64, maxBackups int) AuditSink {
+	return &auditFileSink{filename: filename, maxSize: maxSize, maxBackups: maxBackups}
+}
+
+func (s *auditFileSink) Write(record *AuditRecord) (err error) {
+	line, err := utils.MarshalWithoutEscapeHTML(record)
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		if err = s.open(); err != nil {
+			return
+		}
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return
+}
+
+func (s *auditFileSink) open() (err error) {
+	if err = os.MkdirAll(filepath.Dir(s.filename), os.ModePerm); err != nil {
+		return
+	}
+
+	if s.file, err = os.OpenFile(s.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return
+	}
+
+	s.size = info.Size()
+	return
+}
+
+func (s *auditFileSink) rotate() (err error) {
+	if err = s.file.Close(); err != nil {
+		return
+	}
+
+	s.file = nil
+	backupName := s.filename + "." + time.Now().Format(auditFileTimeLayout)
+	if err = os.Rename(s.filename, backupName); err != nil {
+		return
+	}
+
+	if s.maxBackups > 0 {
+		backups, _ := filepath.Glob(s.filename + ".*")
+		sort.Strings(backups)
+		for len(backups) > s.maxBackups {
+			_ = os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return s.open()
+}
+
+type auditHttpSink struct {
+	url     string
+	headers types.RequestHeaders
+}
+
+// NewAuditHttpSink 将每条审计记录以 JSON 格式 POST 到指定地址
+func NewAuditHttpSink(url string, headers types.RequestHeaders) AuditSink {
+	if headers == nil {
+		headers = types.RequestHeaders{}
+	}
+	if headers.Get("Content-Type") == "" {
+		headers["Content-Type"] = "application/json"
+	}
+	return &auditHttpSink{url: url, headers: headers}
+}
+
+func (s *auditHttpSink) Write(record *AuditRecord) error {
+	if s.url == "" {
+		return errors.New("audit http sink url is empty")
+	}
+
+	body, err := utils.MarshalWithoutEscapeHTML(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.HttpPost(s.url, body, s.headers, 10)
+	return err
+}
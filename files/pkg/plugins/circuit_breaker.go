@@ -0,0 +1,195 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CircuitState string
+
+const (
+	CircuitState_closed   CircuitState = "closed"
+	CircuitState_open     CircuitState = "open"
+	CircuitState_halfOpen CircuitState = "halfOpen"
+)
+
+const circuitBreakerHealthState = "circuitBreaker"
+
+// ErrCircuitOpen 熔断期间请求 Fireboom 节点时直接返回，可通过 errors.Is 判断
+var ErrCircuitOpen = errors.New("circuit breaker of fireboom node is open")
+
+type (
+	CircuitBreakerOptions struct {
+		// 连续失败次数达到阈值时熔断，为 0 时不熔断（默认）
+		FailureThreshold int
+		// 熔断持续时间，之后进入半开状态放行探测请求
+		OpenTimeout time.Duration
+		// 半开状态下同时放行的探测请求数，探测成功后恢复，失败则重新熔断
+		HalfOpenRequests int
+	}
+	CircuitBreakerStatus struct {
+		State    CircuitState `json:"state"`
+		Failures int          `json:"failures"`
+		OpenedAt *time.Time   `json:"openedAt,omitempty"`
+	}
+	circuitBreaker struct {
+		options  CircuitBreakerOptions
+		state    CircuitState
+		failures int
+		openedAt time.Time
+		probing  int
+		mutex    sync.Mutex
+	}
+)
+
+// nodeCircuitBreaker 默认不熔断，通过 ConfigureCircuitBreaker 开启
+var nodeCircuitBreaker = &circuitBreaker{
+	options: CircuitBreakerOptions{
+		OpenTimeout:      5 * time.Second,
+		HalfOpenRequests: 1,
+	},
+	state: CircuitState_closed,
+}
+
+func init() {
+	types.AddHealthStateFunc(circuitBreakerHealthState, func() any {
+		return CircuitBreakerState()
+	})
+}
+
+func ConfigureCircuitBreaker(options CircuitBreakerOptions) {
+	nodeCircuitBreaker.mutex.Lock()
+	defer nodeCircuitBreaker.mutex.Unlock()
+	nodeCircuitBreaker.options = options
+	nodeCircuitBreaker.state = CircuitState_closed
+	nodeCircuitBreaker.failures = 0
+	nodeCircuitBreaker.probing = 0
+}
+
+func CircuitBreakerState() CircuitBreakerStatus {
+	nodeCircuitBreaker.mutex.Lock()
+	defer nodeCircuitBreaker.mutex.Unlock()
+	status := CircuitBreakerStatus{State: nodeCircuitBreaker.state, Failures: nodeCircuitBreaker.failures}
+	if nodeCircuitBreaker.state != CircuitState_closed {
+		openedAt := nodeCircuitBreaker.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow 熔断或半开状态探测名额已满时返回 ErrCircuitOpen，probe 表示该请求为半开状态的探测请求
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.options.FailureThreshold <= 0 {
+		return
+	}
+
+	switch b.state {
+	case CircuitState_open:
+		if time.Since(b.openedAt) < b.options.OpenTimeout {
+			err = ErrCircuitOpen
+			return
+		}
+		b.state, b.probing = CircuitState_halfOpen, 0
+		fallthrough
+	case CircuitState_halfOpen:
+		halfOpenRequests := b.options.HalfOpenRequests
+		if halfOpenRequests <= 0 {
+			halfOpenRequests = 1
+		}
+		if b.probing >= halfOpenRequests {
+			err = ErrCircuitOpen
+			return
+		}
+		b.probing++
+		probe = true
+	}
+	return
+}
+
+// opened 仅判断是否处于熔断期，不占用半开状态的探测名额
+func (b *circuitBreaker) opened() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.options.FailureThreshold > 0 && b.state == CircuitState_open && time.Since(b.openedAt) < b.options.OpenTimeout
+}
+
+// record 探测请求的结果决定半开状态恢复或重新熔断，关闭状态下放行的请求仅在仍处于关闭状态时计数
+func (b *circuitBreaker) record(probe, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.options.FailureThreshold <= 0 {
+		return
+	}
+
+	if probe {
+		if b.state != CircuitState_halfOpen {
+			return
+		}
+		if b.probing > 0 {
+			b.probing--
+		}
+		if failed {
+			b.open()
+		} else {
+			b.state, b.failures = CircuitState_closed, 0
+			log.Infof("circuit breaker of fireboom node closed")
+		}
+		return
+	}
+	if b.state != CircuitState_closed {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	if b.failures++; b.failures >= b.options.FailureThreshold {
+		b.open()
+	}
+}
+
+// release 请求被取消时不计入结果，仅归还探测名额
+func (b *circuitBreaker) release(probe bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if probe && b.state == CircuitState_halfOpen && b.probing > 0 {
+		b.probing--
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.state, b.openedAt = CircuitState_open, time.Now()
+	log.Errorf("circuit breaker of fireboom node opened after %d failure(s)", b.failures)
+}
+
+// doWithCircuitBreaker 仅传输错误以及节点不可用的 502/503/504 计为失败，operation 自身的错误和上下文取消的请求不计入
+func doWithCircuitBreaker(client *http.Client, req *http.Request) (resp *http.Response, err error) {
+	probe, err := nodeCircuitBreaker.allow()
+	if err != nil {
+		return
+	}
+
+	resp, err = client.Do(req)
+	if err != nil {
+		nodeReadinessService.observe(err)
+		if errors.Is(err, context.Canceled) {
+			nodeCircuitBreaker.release(probe)
+		} else {
+			nodeCircuitBreaker.record(probe, true)
+		}
+		return
+	}
+	nodeCircuitBreaker.record(probe, nodeUnavailableStatus(resp.StatusCode))
+	return
+}
+
+func nodeUnavailableStatus(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
@@ -0,0 +1,166 @@
+package plugins
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type circuitBreakerStep struct {
+	// allow 调用 allow 并按 failed 记录结果，否则仅调整时间
+	allow    bool
+	failed   bool
+	canceled bool
+	// elapse 将熔断时间提前，模拟经过的时长
+	elapse      time.Duration
+	expectErr   error
+	expectProbe bool
+	expectState CircuitState
+}
+
+func newTestCircuitBreaker(options CircuitBreakerOptions) *circuitBreaker {
+	return &circuitBreaker{options: options, state: CircuitState_closed}
+}
+
+func TestCircuitBreakerAllowRecord(t *testing.T) {
+	options := CircuitBreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1}
+	tests := []struct {
+		name    string
+		options CircuitBreakerOptions
+		steps   []circuitBreakerStep
+	}{
+		{
+			name:    "disabled by default",
+			options: CircuitBreakerOptions{},
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_closed},
+			},
+		},
+		{
+			name:    "opens after consecutive failures",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_open},
+				{allow: true, expectErr: ErrCircuitOpen, expectState: CircuitState_open},
+			},
+		},
+		{
+			name:    "success resets failures",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true, expectState: CircuitState_closed},
+				{allow: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_closed},
+			},
+		},
+		{
+			name:    "canceled requests are not counted",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true, expectState: CircuitState_closed},
+				{allow: true, canceled: true, expectState: CircuitState_closed},
+				{allow: true, canceled: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_open},
+			},
+		},
+		{
+			name:    "successful probe closes",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true},
+				{allow: true, failed: true, expectState: CircuitState_open},
+				{elapse: time.Minute, expectState: CircuitState_open},
+				{allow: true, expectProbe: true, expectState: CircuitState_closed},
+				{allow: true, failed: true, expectState: CircuitState_closed},
+			},
+		},
+		{
+			name:    "failed probe reopens",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true},
+				{allow: true, failed: true, expectState: CircuitState_open},
+				{elapse: time.Minute},
+				{allow: true, failed: true, expectProbe: true, expectState: CircuitState_open},
+				{allow: true, expectErr: ErrCircuitOpen, expectState: CircuitState_open},
+			},
+		},
+		{
+			name:    "canceled probe releases its slot",
+			options: options,
+			steps: []circuitBreakerStep{
+				{allow: true, failed: true},
+				{allow: true, failed: true, expectState: CircuitState_open},
+				{elapse: time.Minute},
+				{allow: true, canceled: true, expectProbe: true, expectState: CircuitState_halfOpen},
+				{allow: true, expectProbe: true, expectState: CircuitState_closed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestCircuitBreaker(tt.options)
+			for i, step := range tt.steps {
+				if step.elapse > 0 {
+					b.openedAt = b.openedAt.Add(-step.elapse)
+				}
+				if step.allow {
+					probe, err := b.allow()
+					if !errors.Is(err, step.expectErr) {
+						t.Fatalf("step %d: expected err [%v] but got [%v]", i, step.expectErr, err)
+					}
+					if probe != step.expectProbe {
+						t.Fatalf("step %d: expected probe [%v] but got [%v]", i, step.expectProbe, probe)
+					}
+					if err == nil {
+						if step.canceled {
+							b.release(probe)
+						} else {
+							b.record(probe, step.failed)
+						}
+					}
+				}
+				if step.expectState != "" && b.state != step.expectState {
+					t.Fatalf("step %d: expected state [%s] but got [%s]", i, step.expectState, b.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenLimit(t *testing.T) {
+	b := newTestCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 2})
+	b.record(false, true)
+	b.openedAt = b.openedAt.Add(-time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if probe, err := b.allow(); err != nil || !probe {
+			t.Fatalf("probe %d: expected admitted probe but got probe [%v] err [%v]", i, probe, err)
+		}
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once probe slots are used but got [%v]", err)
+	}
+
+	// 熔断前放行的请求在半开状态下结束，不影响探测结果
+	b.record(false, false)
+	if b.state != CircuitState_halfOpen {
+		t.Fatalf("expected non-probe result ignored in halfOpen but got [%s]", b.state)
+	}
+	b.record(true, false)
+	if b.state != CircuitState_closed {
+		t.Fatalf("expected closed after successful probe but got [%s]", b.state)
+	}
+}
+
+func TestNodeUnavailableStatus(t *testing.T) {
+	tests := map[int]bool{200: false, 400: false, 429: false, 500: false, 502: true, 503: true, 504: true}
+	for statusCode, expected := range tests {
+		if actual := nodeUnavailableStatus(statusCode); actual != expected {
+			t.Fatalf("status %d: expected [%v] but got [%v]", statusCode, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type (
+	// FanOut 并发执行多个内部调用，限制并发数并收集每个调用的错误
+	FanOut struct {
+		ctx      context.Context
+		cancel   context.CancelFunc
+		limit    chan struct{}
+		failFast bool
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		errors   FanOutErrors
+	}
+	FanOutResult[O any] struct {
+		Name string
+		data O
+		err  error
+		done chan struct{}
+	}
+	FanOutError struct {
+		Name string
+		Err  error
+	}
+	FanOutErrors []*FanOutError
+)
+
+func (e *FanOutError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Err.Error())
+}
+
+func (e *FanOutError) Unwrap() error {
+	return e.Err
+}
+
+func (e FanOutErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, item := range e {
+		messages = append(messages, item.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NewFanOut concurrency 小于等于 0 时不限制并发，failFast 为 true 时任一调用失败即取消其余调用
+func NewFanOut(ctx context.Context, concurrency int, failFast ...bool) *FanOut {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	f := &FanOut{failFast: len(failFast) > 0 && failFast[0]}
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	if concurrency > 0 {
+		f.limit = make(chan struct{}, concurrency)
+	}
+	return f
+}
+
+func (f *FanOut) Context() context.Context {
+	return f.ctx
+}
+
+// FanOutGo 在 FanOut 中执行任意函数，返回的结果在 Wait 之后或通过 Get 阻塞获取
+func FanOutGo[O any](f *FanOut, name string, execute func(context.Context) (O, error)) *FanOutResult[O] {
+	result := &FanOutResult[O]{Name: name, done: make(chan struct{})}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer close(result.done)
+		if f.limit != nil {
+			select {
+			case f.limit <- struct{}{}:
+				defer func() { <-f.limit }()
+			case <-f.ctx.Done():
+				f.fail(result.Name, f.ctx.Err(), &result.err)
+				return
+			}
+		}
+		if err := f.ctx.Err(); err != nil {
+			f.fail(result.Name, err, &result.err)
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				f.fail(result.Name, fmt.Errorf("panic: %v", r), &result.err)
+			}
+		}()
+		data, err := execute(f.ctx)
+		if err != nil {
+			f.fail(result.Name, err, &result.err)
+			return
+		}
+		result.data = data
+	}()
+	return result
+}
+
+// FanOutExecute 在 FanOut 中执行 operation，名称为 operation 路径
+func FanOutExecute[I, O any](f *FanOut, meta *Meta[I, O], input I, client *types.InternalClient) *FanOutResult[O] {
+	return FanOutGo(f, meta.Path, func(ctx context.Context) (O, error) {
+		return meta.ExecuteContext(ctx, input, client)
+	})
+}
+
+func (f *FanOut) fail(name string, err error, resultErr *error) {
+	*resultErr = err
+	f.mutex.Lock()
+	f.errors = append(f.errors, &FanOutError{Name: name, Err: err})
+	f.mutex.Unlock()
+	if f.failFast {
+		f.cancel()
+	}
+}
+
+// Wait 等待所有调用结束，存在失败时返回 FanOutErrors
+func (f *FanOut) Wait() error {
+	f.wg.Wait()
+	f.cancel()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.errors) == 0 {
+		return nil
+	}
+	return f.errors
+}
+
+func (r *FanOutResult[O]) Get() (O, error) {
+	<-r.done
+	return r.data, r.err
+}
@@ -26,7 +26,7 @@ func RegisterFunction[I, O any](hookFunc func(*types.HookRequest, *types.Operati
 
 	types.AddEchoRouterFunc(func(e *echo.Echo) {
 		e.Logger.Debugf(`Registered hookFunction [%s]`, apiPath)
-		e.POST(apiPath, buildOperationHook(callerName, types.MiddlewareHook(types.HookParent_function), hookFunc))
+		e.POST(apiPath, buildOperationHook(callerName, AuditTarget_function, types.MiddlewareHook(types.HookParent_function), hookFunc))
 	})
 
 	types.AddHealthFunc(func(e *echo.Echo, report *types.HealthReportLock) {
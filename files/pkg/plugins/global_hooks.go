@@ -47,14 +47,18 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
 
-			newReq, err := globalHooks.HttpTransport.BeforeOriginRequest(brc, &reqBody)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-			}
 			resp := types.MiddlewareHookResponse{
 				Op:   reqBody.Name,
 				Hook: types.MiddlewareHook_beforeOriginRequest,
 			}
+			if !globalHookEnabled(c) {
+				return c.JSON(http.StatusOK, resp)
+			}
+
+			newReq, err := globalHooks.HttpTransport.BeforeOriginRequest(brc, &reqBody)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
 			if newReq != nil {
 				resp.Response = types.OnRequestHookResponse{Request: newReq}
 			}
@@ -73,14 +77,18 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
 
-			newResp, err := globalHooks.HttpTransport.AfterOriginResponse(brc, &respBody)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-			}
 			resp := types.MiddlewareHookResponse{
 				Op:   respBody.Name,
 				Hook: types.MiddlewareHook_afterOriginResponse,
 			}
+			if !globalHookEnabled(c) {
+				return c.JSON(http.StatusOK, resp)
+			}
+
+			newResp, err := globalHooks.HttpTransport.AfterOriginResponse(brc, &respBody)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
 			if newResp != nil {
 				resp.Response = types.OnResponseHookResponse{Response: newResp}
 			}
@@ -88,7 +96,7 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 		})
 	}
 
-	if globalHooks.HttpTransport.OnOriginRequest != nil {
+	if globalHooks.HttpTransport.OnOriginRequest != nil || requestSigningEnabled() {
 		apiPath := string(types.Endpoint_onOriginRequest)
 		e.Logger.Debugf(`Registered globalHook [%s]`, apiPath)
 		e.POST(apiPath, func(c echo.Context) error {
@@ -99,14 +107,27 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
 
-			newReq, err := globalHooks.HttpTransport.OnOriginRequest(brc, &reqBody)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-			}
 			resp := types.MiddlewareHookResponse{
 				Op:   reqBody.Name,
 				Hook: types.MiddlewareHook_onOriginRequest,
 			}
+			var newReq *types.WunderGraphRequest
+			if globalHooks.HttpTransport.OnOriginRequest != nil && globalHookEnabled(c) {
+				if newReq, err = globalHooks.HttpTransport.OnOriginRequest(brc, &reqBody); err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+				}
+				if newReq != nil {
+					reqBody.Request = newReq
+				}
+			}
+			// 签名在钩子之后进行，钩子被开关关闭时仍然签名
+			signedReq, err := signOriginRequest(&reqBody)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			if signedReq != nil {
+				newReq = signedReq
+			}
 			if newReq != nil {
 				resp.Response = types.OnRequestHookResponse{Request: newReq}
 			}
@@ -125,14 +146,18 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
 
-			newResp, err := globalHooks.HttpTransport.OnOriginResponse(brc, &respBody)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-			}
 			resp := types.MiddlewareHookResponse{
 				Op:   respBody.Name,
 				Hook: types.MiddlewareHook_onOriginResponse,
 			}
+			if !globalHookEnabled(c) {
+				return c.JSON(http.StatusOK, resp)
+			}
+
+			newResp, err := globalHooks.HttpTransport.OnOriginResponse(brc, &respBody)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
 			if newResp != nil {
 				resp.Response = types.OnResponseHookResponse{Response: newResp}
 			}
@@ -150,6 +175,9 @@ func RegisterGlobalHooks(e *echo.Echo, globalHooks GlobalConfiguration) {
 			if err != nil {
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
+			if !globalHookEnabled(c) {
+				return c.JSON(http.StatusOK, types.MiddlewareHookResponse{Hook: types.MiddlewareHook_onConnectionInit})
+			}
 			resp, err := globalHooks.WsTransport.OnConnectionInit(brc, &reqBody)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
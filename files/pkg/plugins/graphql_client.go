@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GraphqlEndpointPath 节点 GraphQL 端点相对节点地址的路径
+var GraphqlEndpointPath = "/graphql"
+
+// ErrGraphqlEndpointDisabled 未开启 enableGraphqlEndpoint 或 dangerouslyEnableGraphQLEndpoint 时返回
+var ErrGraphqlEndpointDisabled = errors.New("graphql endpoint of fireboom node is disabled")
+
+// GraphqlRequest 任意 GraphQL 文档及其变量，OperationName 在文档包含多个 operation 时必填
+type GraphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// 转发原始请求头时忽略的请求头，由本次请求重新计算
+var graphqlSkippedHeaders = []string{"Content-Length", "Content-Type", "Accept-Encoding", "Host", "Connection"}
+
+func GraphqlEndpointEnabled() bool {
+	return types.WdgGraphConfig.DangerouslyEnableGraphQLEndpoint ||
+		types.WdgGraphConfig.Api != nil && types.WdgGraphConfig.Api.EnableGraphqlEndpoint
+}
+
+// ExecuteGraphql 通过节点的 GraphQL 端点执行任意文档，用于管理工具和数据迁移
+// 经由 client 的 OperationExecutor 执行，path 为 GraphqlEndpointPath、input 为 *GraphqlRequest，
+// 默认实现转发原始请求头、ExtraHeaders（含事务）并与内部调用一致携带 __wg（用户身份），ctx 为 nil 时使用 client 所属钩子请求的上下文
+// 与 Meta.Execute 一致，存在 errors 时返回 *types.OperationErrors，不做重试
+func ExecuteGraphql[O any](ctx context.Context, client *types.InternalClient, request *GraphqlRequest) (result O, err error) {
+	if client == nil {
+		client = defaultInternalClient
+	}
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	// 文档类型未知，按 mutation 处理，不使用查询缓存且不重试
+	executeRequest := &types.OperationRequest{Path: GraphqlEndpointPath, Type: types.OperationType_MUTATION, Input: request}
+	respBytes, err := fetchOperationExecutor(client).Execute(ctx, client, executeRequest)
+	if err != nil {
+		return
+	}
+
+	operation := request.OperationName
+	if operation == "" {
+		operation = strings.TrimPrefix(GraphqlEndpointPath, "/")
+	}
+	return decodeOperationResponse[O](client, operation, respBytes)
+}
+
+// graphqlNodeRequest 与内部调用一致，在请求体中携带 __wg
+type graphqlNodeRequest struct {
+	*GraphqlRequest
+	Wg *types.BaseRequestBodyWg `json:"__wg,omitempty"`
+}
+
+func requestGraphql(ctx context.Context, client *types.InternalClient, request *GraphqlRequest) (respBytes []byte, err error) {
+	if !GraphqlEndpointEnabled() {
+		err = ErrGraphqlEndpointDisabled
+		return
+	}
+	if client.ClientRequest == nil || client.ClientRequest.RequestURI == "" {
+		if err = WaitForNode(ctx); err != nil {
+			return
+		}
+	}
+	nodeRequest := &graphqlNodeRequest{GraphqlRequest: request}
+	if client.BaseRequestBodyWg != nil {
+		nodeRequest.Wg = &types.BaseRequestBodyWg{
+			ClientRequest: &types.WunderGraphRequest{RequestURI: types.PrivateNodeUrl + GraphqlEndpointPath, Method: http.MethodPost},
+			User:          client.User,
+		}
+		if client.ClientRequest != nil {
+			nodeRequest.Wg.ClientRequest.Headers = client.ClientRequest.Headers
+		}
+	}
+	body, err := utils.MarshalWithoutEscapeHTML(nodeRequest)
+	if err != nil {
+		return
+	}
+
+	if policy := fetchRequestPolicy(client, GraphqlEndpointPath); policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, types.PrivateNodeUrl+GraphqlEndpointPath, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	if client.ClientRequest != nil {
+		for k, v := range client.ClientRequest.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	for _, key := range graphqlSkippedHeaders {
+		req.Header.Del(key)
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	for k, v := range client.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := doWithCircuitBreaker(internalHttpClient, req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if respBytes, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	// GraphQL 端点在请求校验失败时可能以非 200 状态码返回 errors
+	if resp.StatusCode != http.StatusOK && !bytes.Contains(respBytes, []byte(`"errors"`)) {
+		if len(respBytes) == 0 {
+			respBytes = []byte(resp.Status)
+		}
+		err = errors.New(string(respBytes))
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package plugins
+
+import (
+	"custom-go/pkg/types"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultHookPriority = 0
+
+type (
+	operationHookHandler[I, O any] struct {
+		priority int
+		resolve  func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)
+	}
+	operationHookChain[I, O any] struct {
+		handlers []*operationHookHandler[I, O]
+	}
+)
+
+var (
+	operationHookChains = make(map[string]any)
+	hookRegisterErrors  []error
+)
+
+// CheckHookRegistrations 返回注册阶段发现的冲突，需在服务启动前调用
+func CheckHookRegistrations() error {
+	if len(hookRegisterErrors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(hookRegisterErrors))
+	for _, err := range hookRegisterErrors {
+		messages = append(messages, err.Error())
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+func addOperationHookHandler[I, O any](apiPath string, priority int,
+	resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) (chain *operationHookChain[I, O], created bool, err error) {
+	handler := &operationHookHandler[I, O]{priority: priority, resolve: resolve}
+	existed, ok := operationHookChains[apiPath]
+	if !ok {
+		chain = &operationHookChain[I, O]{handlers: []*operationHookHandler[I, O]{handler}}
+		operationHookChains[apiPath] = chain
+		created = true
+		return
+	}
+
+	if chain, ok = existed.(*operationHookChain[I, O]); !ok {
+		err = fmt.Errorf("operationHook [%s] registered with different input/output types", apiPath)
+		return
+	}
+	for _, item := range chain.handlers {
+		if item.priority == priority {
+			err = fmt.Errorf("operationHook [%s] registered twice with the same priority [%d]", apiPath, priority)
+			return
+		}
+	}
+
+	chain.handlers = append(chain.handlers, handler)
+	sort.SliceStable(chain.handlers, func(i, j int) bool {
+		return chain.handlers[i].priority > chain.handlers[j].priority
+	})
+	return
+}
+
+// resolve 按优先级依次执行，返回 nil 的处理函数视为不修改；全部返回 nil 时结果也为 nil
+func (c *operationHookChain[I, O]) resolve(hook *types.HookRequest, body *types.OperationBody[I, O]) (out *types.OperationBody[I, O], err error) {
+	for _, handler := range c.handlers {
+		var handlerOut *types.OperationBody[I, O]
+		if handlerOut, err = handler.resolve(hook, body); err != nil {
+			return nil, err
+		}
+		if handlerOut != nil {
+			body, out = handlerOut, handlerOut
+		}
+	}
+	return
+}
@@ -0,0 +1,393 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recordingRedactedValue = "******"
+
+var (
+	defaultRecordPrefixes = []string{"/operation/", "/function/", "/proxy/", "/authentication/", "/upload/"}
+	defaultRecordRedacts  = []string{"authorization", "cookie", "set-cookie", "accessToken", "idToken", "rawAccessToken", "rawIdToken", "password"}
+)
+
+type (
+	RecorderConfiguration struct {
+		// 录制文件保存目录，为空时不录制
+		Dir string
+		// 需要录制的路由前缀，默认录制 operation/function/proxy/authentication/upload
+		Prefixes []string
+		// 额外需要脱敏的字段名，忽略大小写，请求头和请求体中任意层级均生效
+		RedactFields []string
+	}
+	HookRecording struct {
+		Time             time.Time                  `json:"time"`
+		Method           string                     `json:"method"`
+		Path             string                     `json:"path"`
+		Headers          types.RequestHeaders       `json:"headers"`
+		Body             json.RawMessage            `json:"body,omitempty"`
+		StatusCode       int                        `json:"statusCode"`
+		Response         json.RawMessage            `json:"response,omitempty"`
+		InternalRequests []*RecordedInternalRequest `json:"internalRequests,omitempty"`
+
+		mutex  sync.Mutex
+		fields []string
+	}
+	RecordedInternalRequest struct {
+		Path         string            `json:"path"`
+		Subscription bool              `json:"subscription,omitempty"`
+		Input        json.RawMessage   `json:"input,omitempty"`
+		Response     json.RawMessage   `json:"response,omitempty"`
+		Events       []json.RawMessage `json:"events,omitempty"`
+		Error        string            `json:"error,omitempty"`
+	}
+	recordResponseWriter struct {
+		http.ResponseWriter
+		body *bytes.Buffer
+	}
+)
+
+const replayExecutorKey = "replayExecutor"
+
+var recorderConfig RecorderConfiguration
+
+func ConfigureRecorder(config RecorderConfiguration) {
+	if len(config.Prefixes) == 0 {
+		config.Prefixes = defaultRecordPrefixes
+	}
+	config.RedactFields = append(config.RedactFields, defaultRecordRedacts...)
+	recorderConfig = config
+}
+
+// RecordHookMiddleware 需注册在构造 BaseRequestContext 的中间件之后
+func RecordHookMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		if executor, ok := c.Request().Context().Value(replayExecutorKey).(*replayExecutor); ok {
+			if brc, ok := c.(*types.BaseRequestContext); ok && brc.InternalClient != nil {
+				brc.InternalClient.Executor = executor
+			}
+			return next(c)
+		}
+		if recorderConfig.Dir == "" || c.Request().Method == http.MethodGet || !recordRequired(c.Request().URL.Path) {
+			return next(c)
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+		recording := &HookRecording{
+			Time:    time.Now(),
+			Method:  c.Request().Method,
+			Path:    c.Request().URL.RequestURI(),
+			Headers: HeadersToObject(c.Request().Header),
+			fields:  recorderConfig.RedactFields,
+		}
+		if brc, ok := c.(*types.BaseRequestContext); ok && brc.InternalClient != nil {
+			brc.InternalClient.Executor = NewRecordingOperationExecutor(fetchOperationExecutor(brc.InternalClient), recording)
+		}
+		writer := &recordResponseWriter{ResponseWriter: c.Response().Writer, body: &bytes.Buffer{}}
+		c.Response().Writer = writer
+
+		err = next(c)
+		if err != nil {
+			c.Error(err)
+		}
+		recording.StatusCode = c.Response().Status
+		recording.Body = recording.redact(bodyBytes)
+		recording.Response = recording.redact(writer.body.Bytes())
+		recording.Headers = recording.redactHeaders(recording.Headers)
+		if saveErr := recording.save(recorderConfig.Dir); saveErr != nil {
+			log.Errorf("save hook recording failed, err: %v", saveErr.Error())
+		}
+		return nil
+	}
+}
+
+func recordRequired(path string) bool {
+	for _, prefix := range recorderConfig.Prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *recordResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *HookRecording) redact(data []byte) json.RawMessage {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	if !json.Valid(data) {
+		data, _ = json.Marshal(string(data))
+	}
+	return utils.RedactJson(data, r.fields, recordingRedactedValue)
+}
+
+func (r *HookRecording) redactHeaders(headers types.RequestHeaders) types.RequestHeaders {
+	for key := range headers {
+		for _, field := range r.fields {
+			if strings.EqualFold(key, field) {
+				headers[key] = recordingRedactedValue
+				break
+			}
+		}
+	}
+	return headers
+}
+
+func (r *HookRecording) RecordExecute(path string, input any, response []byte, err error) {
+	inputBytes, _ := utils.MarshalWithoutEscapeHTML(input)
+	item := &RecordedInternalRequest{Path: path, Input: r.redact(inputBytes), Response: r.redact(response)}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.InternalRequests = append(r.InternalRequests, item)
+}
+
+func (r *HookRecording) RecordSubscribe(path string, input any) func([]byte) {
+	inputBytes, _ := utils.MarshalWithoutEscapeHTML(input)
+	item := &RecordedInternalRequest{Path: path, Subscription: true, Input: r.redact(inputBytes)}
+	r.mutex.Lock()
+	r.InternalRequests = append(r.InternalRequests, item)
+	r.mutex.Unlock()
+	return func(event []byte) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		item.Events = append(item.Events, r.redact(event))
+	}
+}
+
+func (r *HookRecording) save(dir string) (err error) {
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	content, err := json.MarshalIndent(r, "", "  ")
+	r.mutex.Unlock()
+	if err != nil {
+		return
+	}
+
+	name := strings.NewReplacer("/", "_", "?", "_", "&", "_").Replace(strings.Trim(r.Path, "/"))
+	filename := fmt.Sprintf("%s_%s.json", r.Time.Format("20060102150405.000000"), name)
+	return os.WriteFile(filepath.Join(dir, filename), content, 0644)
+}
+
+func LoadHookRecording(filename string) (recording *HookRecording, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	recording = &HookRecording{fields: recorderConfig.RedactFields}
+	if len(recording.fields) == 0 {
+		recording.fields = defaultRecordRedacts
+	}
+	err = json.Unmarshal(content, recording)
+	return
+}
+
+// replayExecutor 按录制顺序应答内部调用，并记录与录制不一致的入参
+type replayExecutor struct {
+	recording  *HookRecording
+	cursors    map[string]int
+	mismatches []string
+	mutex      sync.Mutex
+}
+
+func (s *replayExecutor) next(path string, subscription bool, input any) (item *RecordedInternalRequest, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var index int
+	for _, recorded := range s.recording.InternalRequests {
+		if recorded.Path != path || recorded.Subscription != subscription {
+			continue
+		}
+		if index == s.cursors[path] {
+			item = recorded
+			break
+		}
+		index++
+	}
+	if item == nil {
+		s.mismatches = append(s.mismatches, fmt.Sprintf("internal request [%s] was not recorded", path))
+		err = fmt.Errorf("internal request [%s] was not recorded", path)
+		return
+	}
+
+	s.cursors[path]++
+	inputBytes, _ := utils.MarshalWithoutEscapeHTML(input)
+	var actualInput, recordedInput any
+	_ = json.Unmarshal(s.recording.redact(inputBytes), &actualInput)
+	_ = json.Unmarshal(item.Input, &recordedInput)
+	for _, diff := range DiffJson(recordedInput, actualInput) {
+		s.mismatches = append(s.mismatches, fmt.Sprintf("internal request [%s] input %s", path, diff))
+	}
+	return
+}
+
+func (s *replayExecutor) Execute(_ context.Context, _ *types.InternalClient, request *types.OperationRequest) ([]byte, error) {
+	item, err := s.next(request.Path, false, request.Input)
+	if err != nil {
+		return nil, err
+	}
+	if item.Error != "" {
+		return nil, errors.New(item.Error)
+	}
+	return item.Response, nil
+}
+
+func (s *replayExecutor) Subscribe(ctx context.Context, _ *types.InternalClient, request *types.OperationRequest) (<-chan *types.SubscriptionEvent, error) {
+	item, err := s.next(request.Path, true, request.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *types.SubscriptionEvent)
+	go func() {
+		defer close(events)
+		for _, event := range item.Events {
+			if !sendSubscriptionEvent(ctx, events, &types.SubscriptionEvent{Data: event}) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (s *replayExecutor) Upload(_ context.Context, _ *types.InternalClient, request *types.UploadRequest) (uploadResp types.UploadedFiles, err error) {
+	item, err := s.next(uploadOperationPath(request.Provider), false, request)
+	if err != nil {
+		return
+	}
+	if item.Error != "" {
+		err = errors.New(item.Error)
+		return
+	}
+	err = json.Unmarshal(item.Response, &uploadResp)
+	return
+}
+
+// ReplayingRequest 判断请求是否由 ReplayHookRecording 发起，回放时不应启动访问节点的后台任务
+func ReplayingRequest(req *http.Request) bool {
+	_, ok := req.Context().Value(replayExecutorKey).(*replayExecutor)
+	return ok
+}
+
+// ReplayHookRecording 使用当前构建重新执行录制的请求，内部调用由录制结果应答，返回输出的差异
+// e 需已注册 RecordHookMiddleware，回放执行器通过请求上下文传递，可并发回放
+func ReplayHookRecording(e *echo.Echo, recording *HookRecording) (diffs []string) {
+	executor := &replayExecutor{recording: recording, cursors: make(map[string]int)}
+
+	body := &bytes.Buffer{}
+	if err := json.Compact(body, recording.Body); err != nil {
+		body = bytes.NewBuffer(recording.Body)
+	}
+	req := httptest.NewRequest(recording.Method, recording.Path, bytes.NewReader(body.Bytes()))
+	req = req.WithContext(context.WithValue(req.Context(), replayExecutorKey, executor))
+	for k, v := range recording.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(echo.HeaderContentLength, strconv.Itoa(body.Len()))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != recording.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status code expected %d but got %d", recording.StatusCode, rec.Code))
+	}
+	var expected, actual any
+	_ = json.Unmarshal(recording.Response, &expected)
+	_ = json.Unmarshal(recording.redact(rec.Body.Bytes()), &actual)
+	for _, diff := range DiffJson(expected, actual) {
+		diffs = append(diffs, "response "+diff)
+	}
+	diffs = append(diffs, executor.mismatches...)
+	return
+}
+
+// DiffJson 比较两个反序列化后的 JSON 值，返回以 JSON 路径描述的差异
+func DiffJson(expected, actual any) (diffs []string) {
+	diffJsonValue("$", expected, actual, &diffs)
+	return
+}
+
+func diffJsonValue(path string, expected, actual any, diffs *[]string) {
+	switch expectedValue := expected.(type) {
+	case map[string]any:
+		actualValue, ok := actual.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for key := range expectedValue {
+			keys[key] = true
+		}
+		for key := range actualValue {
+			keys[key] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for key := range keys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+		for _, key := range sortedKeys {
+			diffJsonValue(path+"."+key, expectedValue[key], actualValue[key], diffs)
+		}
+		return
+	case []any:
+		actualValue, ok := actual.([]any)
+		if !ok {
+			break
+		}
+		if len(expectedValue) != len(actualValue) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %d item(s) but got %d", path, len(expectedValue), len(actualValue)))
+			return
+		}
+		for i := range expectedValue {
+			diffJsonValue(fmt.Sprintf("%s[%d]", path, i), expectedValue[i], actualValue[i], diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		expectedBytes, _ := json.Marshal(expected)
+		actualBytes, _ := json.Marshal(actual)
+		*diffs = append(*diffs, fmt.Sprintf("%s: expected %s but got %s", path, expectedBytes, actualBytes))
+	}
+}
@@ -0,0 +1,203 @@
+package plugins
+
+import (
+	"crypto/subtle"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"golang.org/x/exp/slices"
+	"net/http"
+	"sync"
+)
+
+type ToggleMode string
+
+const (
+	ToggleMode_enabled  ToggleMode = "enabled"
+	ToggleMode_disabled ToggleMode = "disabled"
+	ToggleMode_mock     ToggleMode = "mock"
+)
+
+const (
+	hookToggleAdminPath   = "/admin/hookToggles"
+	hookToggleAdminHeader = "X-Admin-Token"
+)
+
+type (
+	HookToggle struct {
+		Mode ToggleMode `json:"mode"`
+		// Users/Roles 非空时仅匹配的用户（userId 或任一角色）按 Mode 执行，其余用户视为 enabled
+		Users []string `json:"users,omitempty"`
+		Roles []string `json:"roles,omitempty"`
+		// mock 模式下作为响应 data 返回，仅对 operation 钩子和函数生效，其余钩子视为 disabled
+		Mock json.RawMessage `json:"mock,omitempty"`
+	}
+	// ToggleConfiguration 以钩子的路由路径为键，例如 /operation/Todo/GetList/postResolve、/function/xxx、/global/httpTransport/onOriginRequest
+	ToggleConfiguration struct {
+		Hooks map[string]*HookToggle `json:"hooks"`
+	}
+	ToggleOptions struct {
+		// 监听的 ToggleConfiguration JSON 文件，文件修改后自动生效
+		File string
+		// 非空时注册 GET/PUT /admin/hookToggles，请求头 X-Admin-Token 需与之一致
+		AdminToken string
+	}
+)
+
+var (
+	hookToggles      = make(map[string]*HookToggle)
+	hookTogglesMutex sync.RWMutex
+	hookToggleFile   string
+)
+
+func ConfigureHookToggles(options ToggleOptions) {
+	hookTogglesMutex.Lock()
+	hookToggleFile = options.File
+	hookTogglesMutex.Unlock()
+	if options.AdminToken == "" {
+		return
+	}
+
+	types.AddEchoRouterFunc(func(e *echo.Echo) {
+		e.Logger.Debugf(`Registered hookToggles admin [%s]`, hookToggleAdminPath)
+		e.GET(hookToggleAdminPath, func(c echo.Context) error {
+			return c.JSON(http.StatusOK, ToggleConfiguration{Hooks: HookToggles()})
+		}, hookToggleAdminAuth(options.AdminToken))
+		e.PUT(hookToggleAdminPath, func(c echo.Context) error {
+			var config ToggleConfiguration
+			if err := c.Bind(&config); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			for path, toggle := range config.Hooks {
+				if toggle == nil {
+					DeleteHookToggle(path)
+				} else {
+					SetHookToggle(path, toggle)
+				}
+			}
+			return c.JSON(http.StatusOK, ToggleConfiguration{Hooks: HookToggles()})
+		}, hookToggleAdminAuth(options.AdminToken))
+	})
+}
+
+func hookToggleAdminAuth(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if subtle.ConstantTimeCompare([]byte(c.Request().Header.Get(hookToggleAdminHeader)), []byte(token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid admin token")
+			}
+			return next(c)
+		}
+	}
+}
+
+// SetHookToggle 运行时设置的开关优先于文件中的配置
+func SetHookToggle(path string, toggle *HookToggle) {
+	hookTogglesMutex.Lock()
+	defer hookTogglesMutex.Unlock()
+	hookToggles[path] = toggle
+}
+
+func DeleteHookToggle(path string) {
+	hookTogglesMutex.Lock()
+	defer hookTogglesMutex.Unlock()
+	delete(hookToggles, path)
+}
+
+// HookToggles 返回文件与运行时合并后的开关
+func HookToggles() map[string]*HookToggle {
+	hookTogglesMutex.RLock()
+	configFile := hookToggleFile
+	result := make(map[string]*HookToggle, len(hookToggles))
+	for path, toggle := range hookToggles {
+		result[path] = toggle
+	}
+	hookTogglesMutex.RUnlock()
+
+	for path, toggle := range fileHookToggles(configFile) {
+		if _, ok := result[path]; !ok && toggle != nil {
+			result[path] = toggle
+		}
+	}
+	return result
+}
+
+func fetchHookToggle(path string) *HookToggle {
+	hookTogglesMutex.RLock()
+	toggle, ok := hookToggles[path]
+	configFile := hookToggleFile
+	hookTogglesMutex.RUnlock()
+	if ok {
+		return toggle
+	}
+	return fileHookToggles(configFile)[path]
+}
+
+// fileHookToggles 文件仅在修改后重新解析，返回的 map 为缓存，不可修改
+func fileHookToggles(configFile string) map[string]*HookToggle {
+	if configFile == "" || utils.NotExistFile(configFile) {
+		return nil
+	}
+	config, err := utils.ParseAndCacheFile(configFile, func(content []byte) (config *ToggleConfiguration, err error) {
+		err = json.Unmarshal(content, &config)
+		if err == nil && config == nil {
+			config = &ToggleConfiguration{}
+		}
+		return
+	})
+	if err != nil {
+		log.Errorf("read hook toggles [%s] failed, err: %v", configFile, err.Error())
+		return nil
+	}
+	return config.Hooks
+}
+
+// hookToggleMode 返回钩子对当前用户生效的模式，未配置或用户不匹配 Users/Roles 时为 enabled
+func hookToggleMode(path string, user *types.User) (mode ToggleMode, toggle *HookToggle) {
+	if toggle = fetchHookToggle(path); toggle == nil {
+		mode = ToggleMode_enabled
+		return
+	}
+	if !toggle.matched(user) {
+		mode = ToggleMode_enabled
+		return
+	}
+	if mode = toggle.Mode; mode == "" {
+		mode = ToggleMode_enabled
+	}
+	return
+}
+
+func (t *HookToggle) matched(user *types.User) bool {
+	if len(t.Users) == 0 && len(t.Roles) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if slices.Contains(t.Users, user.UserId) {
+		return true
+	}
+	for _, role := range user.Roles {
+		if slices.Contains(t.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// globalHookEnabled 全局钩子关闭（或 mock）时直接放行
+func globalHookEnabled(c echo.Context) bool {
+	var user *types.User
+	if brc, ok := c.(*types.BaseRequestContext); ok && brc.InternalClient != nil {
+		user = brc.User
+	}
+	mode, _ := hookToggleMode(c.Path(), user)
+	if mode != ToggleMode_enabled {
+		c.Logger().Debugf("globalHook [%s] skipped by toggle [%s]", c.Path(), mode)
+		return false
+	}
+	return true
+}
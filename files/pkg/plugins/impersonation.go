@@ -0,0 +1,154 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"github.com/tidwall/gjson"
+	"golang.org/x/exp/slices"
+	"sync"
+)
+
+type (
+	// ImpersonationPolicy InternalClient.AsUser/AsService 的允许策略，未配置时拒绝所有身份切换
+	ImpersonationPolicy struct {
+		// 允许通过 AsUser 切换为任意用户
+		AllowUsers bool
+		// 允许通过 AsService 使用的服务名称及其可携带的角色，角色为 nil 时不限制
+		Services map[string][]string
+		// 非空时在上述规则通过后再次校验，返回错误时拒绝
+		Allow func(client *types.InternalClient, impersonation *types.Impersonation) error
+	}
+	// impersonatedOperationExecutor 派生客户端的每次内部调用在开启 impersonation 审计时写入审计记录
+	impersonatedOperationExecutor struct {
+		next types.OperationExecutor
+	}
+)
+
+var (
+	impersonationPolicy      *ImpersonationPolicy
+	impersonationPolicyMutex sync.RWMutex
+)
+
+func init() {
+	types.SetImpersonationGuard(guardImpersonation)
+}
+
+func ConfigureImpersonation(policy *ImpersonationPolicy) {
+	impersonationPolicyMutex.Lock()
+	defer impersonationPolicyMutex.Unlock()
+	impersonationPolicy = policy
+}
+
+// guardImpersonation 校验策略，通过和拒绝均记录日志，开启 impersonation 审计时写入审计记录
+func guardImpersonation(client *types.InternalClient, impersonation *types.Impersonation) (err error) {
+	impersonationPolicyMutex.RLock()
+	policy := impersonationPolicy
+	impersonationPolicyMutex.RUnlock()
+
+	target := impersonationTarget(impersonation)
+	requestId := client.ExtraHeaders.Get(string(types.InternalHeader_X_Request_Id))
+	record := newImpersonationAuditRecord(client, impersonation, target, nil)
+	defer func() {
+		record.finish(nil, err)
+		if err != nil {
+			log.Warnf("impersonation [%s] denied, requestId: %s, err: %v", target, requestId, err)
+			return
+		}
+		log.Infof("impersonation [%s] allowed, requestId: %s, from: %s", target, requestId, impersonationFromName(impersonation))
+	}()
+
+	if err = checkImpersonationPolicy(policy, impersonation); err != nil {
+		return
+	}
+	if policy.Allow != nil {
+		err = policy.Allow(client, impersonation)
+	}
+	return
+}
+
+func checkImpersonationPolicy(policy *ImpersonationPolicy, impersonation *types.Impersonation) error {
+	if policy == nil {
+		return types.ErrImpersonationDenied
+	}
+	if impersonation.Service == "" {
+		if !policy.AllowUsers {
+			return types.ErrImpersonationDenied
+		}
+		return nil
+	}
+
+	allowedRoles, ok := policy.Services[impersonation.Service]
+	if !ok {
+		return fmt.Errorf("%w: service [%s] is not allowed", types.ErrImpersonationDenied, impersonation.Service)
+	}
+	if allowedRoles == nil {
+		return nil
+	}
+	for _, role := range impersonation.To.Roles {
+		if !slices.Contains(allowedRoles, role) {
+			return fmt.Errorf("%w: role [%s] is not allowed for service [%s]", types.ErrImpersonationDenied, role, impersonation.Service)
+		}
+	}
+	return nil
+}
+
+func impersonationTarget(impersonation *types.Impersonation) string {
+	if impersonation.Service != "" {
+		return "service:" + impersonation.Service
+	}
+	return "user:" + impersonation.To.UserId
+}
+
+func impersonationFromName(impersonation *types.Impersonation) string {
+	if impersonation.From == nil {
+		return "-"
+	}
+	return impersonation.From.UserId
+}
+
+// newImpersonationAuditRecord 身份切换的 path 为 user:xxx 或 service:xxx，派生客户端的调用为 operation 路径
+func newImpersonationAuditRecord(client *types.InternalClient, impersonation *types.Impersonation, path string, input []byte) *AuditRecord {
+	record := newAuditRecord(nil, AuditTarget_impersonation, path, "", input)
+	if record != nil {
+		record.RequestId = client.ExtraHeaders.Get(string(types.InternalHeader_X_Request_Id))
+		record.User = newAuditUser(impersonation.From)
+		record.Impersonated = newAuditUser(impersonation.To)
+	}
+	return record
+}
+
+func (e *impersonatedOperationExecutor) auditRecord(client *types.InternalClient, path string, input any) *AuditRecord {
+	if !auditEnabled(AuditTarget_impersonation) {
+		return nil
+	}
+	inputBytes, _ := utils.MarshalWithoutEscapeHTML(input)
+	return newImpersonationAuditRecord(client, client.Impersonation, path, inputBytes)
+}
+
+func (e *impersonatedOperationExecutor) Execute(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (respBytes []byte, err error) {
+	record := e.auditRecord(client, request.Path, request.Input)
+	defer func() { record.finish([]byte(gjson.GetBytes(respBytes, "data").Raw), err) }()
+	return e.next.Execute(ctx, client, request)
+}
+
+// Subscribe 仅记录订阅的建立
+func (e *impersonatedOperationExecutor) Subscribe(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (events <-chan *types.SubscriptionEvent, err error) {
+	record := e.auditRecord(client, request.Path, request.Input)
+	defer func() { record.finish(nil, err) }()
+	return e.next.Subscribe(ctx, client, request)
+}
+
+func (e *impersonatedOperationExecutor) Upload(ctx context.Context, client *types.InternalClient, request *types.UploadRequest) (uploadResp types.UploadedFiles, err error) {
+	record := e.auditRecord(client, uploadOperationPath(request.Provider), request)
+	defer func() {
+		var respBytes []byte
+		if err == nil {
+			respBytes, _ = utils.MarshalWithoutEscapeHTML(uploadResp)
+		}
+		record.finish(respBytes, err)
+	}()
+	return e.next.Upload(ctx, client, request)
+}
@@ -11,49 +11,27 @@ import (
 	"github.com/google/uuid"
 	"github.com/graphql-go/graphql/gqlerrors"
 	"github.com/labstack/echo/v4"
-	"github.com/r3labs/sse/v2"
 	"github.com/tidwall/sjson"
 	"golang.org/x/exp/maps"
 	"io"
-	"math"
-	"mime/multipart"
 	"net/http"
-	"reflect"
 	"strings"
-	"sync"
-	"time"
 )
 
-var waitInternalUntilReadyOnce sync.Once
-
-func waitInternalUntilReady() {
-	rootPath := fetchInternalRequestUrl("/")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
-	defer cancel()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			if _, err := http.Get(rootPath); err != nil {
-				time.Sleep(time.Millisecond * 50)
-				continue
-			}
-			return
-		}
-	}
-}
-
-func internalRequest[I any](client *types.InternalClient, path string, options types.OperationArgsWithInput[I]) (resp *http.Response, err error) {
+func internalRequest[I any](client *types.InternalClient, path string, operationType types.OperationType, options types.OperationArgsWithInput[I]) (resp *http.Response, err error) {
 	if client == nil {
 		client = defaultInternalClient
 	}
 	if client.ClientRequest.RequestURI == "" {
-		waitInternalUntilReadyOnce.Do(waitInternalUntilReady)
+		if err = WaitForNode(options.Context); err != nil {
+			return
+		}
 	}
 	var (
-		bodyBuffer  *bytes.Buffer
-		contentType string
+		bodyBytes     []byte
+		streamBody    io.ReadCloser
+		contentType   string
+		contentLength int64
 	)
 	url := fetchInternalRequestUrl(path)
 	baseBodyWg := &types.BaseRequestBodyWg{
@@ -66,16 +44,20 @@ func internalRequest[I any](client *types.InternalClient, path string, options t
 	}
 	formData, ok := options.Context.Value(fileFormDataKey).(fileFormData)
 	if ok {
-		optional := func(writer *multipart.Writer) {
-			inputBytes, _ := utils.MarshalWithoutEscapeHTML(options.Input)
-			for _, key := range maps.Keys(formData) {
-				inputBytes, _ = sjson.DeleteBytes(inputBytes, key)
+		var inputBytes, baseBodyWgBytes []byte
+		if inputBytes, err = utils.MarshalWithoutEscapeHTML(options.Input); err != nil {
+			return
+		}
+		for _, key := range maps.Keys(formData) {
+			if inputBytes, err = sjson.DeleteBytes(inputBytes, key); err != nil {
+				return
 			}
-			_ = writer.WriteField("input", string(inputBytes))
-			baseBodyWgBytes, _ := utils.MarshalWithoutEscapeHTML(baseBodyWg)
-			_ = writer.WriteField("__wg", string(baseBodyWgBytes))
 		}
-		if bodyBuffer, contentType, err = buildBodyWithFileFormData(formData, optional); err != nil {
+		if baseBodyWgBytes, err = utils.MarshalWithoutEscapeHTML(baseBodyWg); err != nil {
+			return
+		}
+		fields := []multipartField{{name: "input", value: string(inputBytes)}, {name: "__wg", value: string(baseBodyWgBytes)}}
+		if streamBody, contentType, contentLength, err = streamFileFormData(options.Context, formData, fields...); err != nil {
 			return
 		}
 	} else {
@@ -83,84 +65,138 @@ func internalRequest[I any](client *types.InternalClient, path string, options t
 		if jsonData, err = utils.MarshalWithoutEscapeHTML(types.OperationHookPayload{Input: options.Input, Wg: baseBodyWg}); err != nil {
 			return
 		}
-		bodyBuffer, contentType = bytes.NewBuffer(utils.ClearZeroTime(jsonData)), echo.MIMEApplicationJSON
+		bodyBytes, contentType = utils.ClearZeroTime(jsonData), echo.MIMEApplicationJSON
+		contentLength = int64(len(bodyBytes))
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bodyBuffer)
-	if err != nil {
-		return
-	}
-
-	req.Header.Set("Content-Type", contentType)
-	for k, v := range client.ExtraHeaders {
-		req.Header.Set(k, v)
+	policy := fetchRequestPolicy(client, path)
+	for attempt := 0; ; attempt++ {
+		var body io.Reader = streamBody
+		if streamBody == nil {
+			body = bytes.NewReader(bodyBytes)
+		}
+		resp, err = doInternalRequest(options.Context, client, url, contentType, body, contentLength, policy, operationType)
+		// 流式上传的请求体无法重放，不重试
+		if streamBody != nil || !retryAllowed(policy, operationType) || attempt >= policy.MaxRetries || !retryRequired(policy, resp, err) {
+			break
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		if sleepErr := sleepContext(options.Context, retryBackoff(policy, attempt)); sleepErr != nil {
+			if err == nil {
+				err = sleepErr
+			}
+			return nil, err
+		}
 	}
-
-	resp, err = http.DefaultClient.Do(req)
 	if err != nil {
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		err = errors.New(string(bodyBytes))
+		respBytes, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if len(respBytes) == 0 {
+			respBytes = []byte(resp.Status)
+		}
+		err = errors.New(string(respBytes))
+		resp = nil
 		return
 	}
 
 	return
 }
 
-func executeInternalRequest[I, OD any](client *types.InternalClient, path string, input I) (result OD, err error) {
-	options := types.OperationArgsWithInput[I]{Input: input}
-	inputValue := reflect.ValueOf(input)
-	if inputValue.Kind() == reflect.Ptr {
-		inputValue = inputValue.Elem()
+func doInternalRequest(ctx context.Context, client *types.InternalClient, url, contentType string, body io.Reader, contentLength int64, policy *types.RequestPolicy, operationType types.OperationType) (resp *http.Response, err error) {
+	cancel := context.CancelFunc(func() {})
+	if policy.Timeout > 0 && operationType != types.OperationType_SUBSCRIPTION {
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
 	}
-	inputType := inputValue.Type()
-	formData := make(fileFormData)
-	for i := 0; i < inputValue.NumField(); i++ {
-		inputFieldValue := inputValue.Field(i)
-		if !inputFieldValue.IsValid() || !inputFieldValue.CanInterface() || inputFieldValue.IsZero() {
-			continue
+	// 熔断器快速失败等未执行 client.Do 的情况需关闭流式请求体，否则写入协程会一直阻塞
+	closeBody := func() {
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
 		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		closeBody()
+		cancel()
+		return
+	}
+	req.ContentLength = contentLength
 
-		var files []*types.UploadFile
-		switch v := inputFieldValue.Interface().(type) {
-		case *types.UploadFile:
-			files = []*types.UploadFile{v}
-		case []*types.UploadFile:
-			files = v
-		}
-		if len(files) > 0 {
-			inputFieldTag := inputType.Field(i).Tag.Get("json")
-			formData[inputFieldTag] = files
-		}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range client.ExtraHeaders {
+		req.Header.Set(k, v)
 	}
-	options.Context = context.Background()
-	if len(formData) > 0 {
-		options.Context = context.WithValue(options.Context, fileFormDataKey, formData)
+
+	if resp, err = doWithCircuitBreaker(internalHttpClient, req); err != nil {
+		closeBody()
+		cancel()
+		return
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return
+}
+
+func executeInternalRequest[I, OD any](ctx context.Context, client *types.InternalClient, path string, operationType types.OperationType, input I) (result OD, err error) {
+	if err = ctx.Err(); err != nil {
+		return
 	}
 
-	resp, err := internalRequest[I](client, path, options)
+	request := &types.OperationRequest{Path: path, Type: operationType, Input: input}
+	respBytes, err := fetchOperationExecutor(client).Execute(ctx, client, request)
 	if err != nil {
 		return
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	var operationResp types.OperationBodyResponse[OD]
-	if err = json.NewDecoder(resp.Body).Decode(&operationResp); err != nil {
+	return decodeOperationResponse[OD](client, path, respBytes)
+}
+
+// decodeOperationResponse 解析 {"data": ..., "errors": [...]}，存在 errors 时返回 *types.OperationErrors
+func decodeOperationResponse[OD any](client *types.InternalClient, operation string, respBytes []byte) (result OD, err error) {
+	var operationResp struct {
+		Data   json.RawMessage         `json:"data"`
+		Errors []*types.OperationError `json:"errors"`
+	}
+	if err = json.Unmarshal(respBytes, &operationResp); err != nil {
 		return
 	}
 
 	if len(operationResp.Errors) > 0 {
-		err = errors.New(operationResp.Errors[0].Message)
+		operationErrors := &types.OperationErrors{Operation: operation, Errors: operationResp.Errors, Data: operationResp.Data}
+		if client != nil && client.PartialData {
+			_ = operationErrors.UnmarshalData(&result)
+		}
+		err = operationErrors
 		return
 	}
 
-	result = operationResp.Data
+	if len(operationResp.Data) > 0 {
+		err = json.Unmarshal(operationResp.Data, &result)
+	}
 	return
 }
 
+func requestInternalOperation[I any](ctx context.Context, client *types.InternalClient, path string, operationType types.OperationType, input I) (respBytes []byte, err error) {
+	options := types.OperationArgsWithInput[I]{Input: input}
+	formData := collectUploadFiles(input)
+	options.Context = ctx
+	if len(formData) > 0 {
+		options.Context = context.WithValue(options.Context, fileFormDataKey, formData)
+	}
+
+	resp, err := internalRequest[I](client, path, operationType, options)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return io.ReadAll(resp.Body)
+}
+
 const fileFormDataKey = "fileFormData"
 
 var (
@@ -180,6 +216,8 @@ type (
 	SubscriberData[O any] struct {
 		Data   O
 		Errors []gqlerrors.FormattedError
+		// Err 与 Errors 对应的原始错误
+		Err error
 	}
 )
 
@@ -192,8 +230,17 @@ func NewOperationMeta[I, O any](path string, operationType types.OperationType)
 	return &Meta[I, O]{Path: path, Type: operationType}
 }
 
+// Execute 绑定 client 所属钩子请求的上下文，客户端请求取消时内部调用随之取消
+// 钩子返回后仍需执行（如异步任务）时请使用 ExecuteContext 传入独立的上下文
 func (m *Meta[I, O]) Execute(input I, client *types.InternalClient) (O, error) {
-	return executeInternalRequest[I, O](client, m.Path, input)
+	return m.ExecuteContext(client.RequestContext(), input, client)
+}
+
+func (m *Meta[I, O]) ExecuteContext(ctx context.Context, input I, client *types.InternalClient) (O, error) {
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	return executeInternalRequest[I, O](ctx, client, m.Path, m.Type, input)
 }
 
 func NewOperationSubscriber[I, O any](path string) *Subscriber[I, O] {
@@ -201,55 +248,12 @@ func NewOperationSubscriber[I, O any](path string) *Subscriber[I, O] {
 	return &Subscriber[I, O]{Path: path}
 }
 
-func (m *Subscriber[I, O]) Subscribe(input I, client *types.InternalClient) (dataChan chan SubscriberData[O], err error) {
-	options := types.OperationArgsWithInput[I]{Input: input, Context: context.Background()}
-	resp, err := internalRequest[I](client, m.Path, options)
-	if err != nil {
-		return
+// ExecuteWithTransaction 会修改 client 的请求头，execute 返回错误或 panic 时回滚
+// Deprecated: 使用 WithTransaction，其返回独立的事务 client 并支持超时和嵌套
+func ExecuteWithTransaction(client *types.InternalClient, execute func() error) (err error) {
+	if nodeCircuitBreaker.opened() {
+		return ErrCircuitOpen
 	}
-
-	dataChan = make(chan SubscriberData[O])
-	go func() {
-		defer func() { _ = resp.Body.Close() }()
-		reader := sse.NewEventStreamReader(resp.Body, math.MaxInt)
-		var (
-			readMsg, lineData []byte
-			data              O
-		)
-		for {
-			if readMsg, err = reader.ReadEvent(); err != nil {
-				if err == io.EOF {
-					return
-				}
-
-				dataChan <- SubscriberData[O]{Errors: []gqlerrors.FormattedError{{Message: internalError}}}
-				return
-			}
-			if len(readMsg) == 0 {
-				continue
-			}
-
-			// normalize the crlf to lf to make it easier to split the lines.
-			// split the line by "\n" or "\r", per the spec.
-			lines := bytes.FieldsFunc(readMsg, func(r rune) bool { return r == '\n' || r == '\r' })
-			for _, line := range lines {
-				if bytes.HasPrefix(line, headerData) {
-					if lineData = trim(line[len(headerData):]); len(lineData) == 0 {
-						continue
-					}
-					if err = json.Unmarshal(lineData, &data); err != nil {
-						dataChan <- SubscriberData[O]{Errors: []gqlerrors.FormattedError{{Message: internalError}}}
-						return
-					}
-					dataChan <- SubscriberData[O]{Data: data}
-				}
-			}
-		}
-	}()
-	return
-}
-
-func ExecuteWithTransaction(client *types.InternalClient, execute func() error) error {
 	if client.ExtraHeaders.Get(string(types.TransactionHeader_X_Transaction_Id)) == "" {
 		transactionId := uuid.New().String()
 		client.WithHeaders(types.RequestHeaders{
@@ -260,14 +264,16 @@ func ExecuteWithTransaction(client *types.InternalClient, execute func() error)
 			string(types.TransactionHeader_X_Transaction_Manually): "true",
 		})
 	}
-	var body []byte
+	notifyCtx := detachedContext{parent: client.RequestContext()}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = notifyTransactionFinish(notifyCtx, client, fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
 	executeErr := execute()
-	if executeErr != nil {
-		body = []byte(fmt.Sprintf(`{"error": "%s"}`, executeErr.Error()))
-	}
-	url := types.PrivateNodeUrl + string(types.InternalEndpoint_internalTransaction)
-	if _, err := utils.HttpPost(url, body, client.ExtraHeaders); err != nil {
-		return err
+	if err = notifyTransactionFinish(notifyCtx, client, executeErr); err != nil {
+		return
 	}
 	return executeErr
 }
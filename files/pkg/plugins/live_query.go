@@ -0,0 +1,154 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"time"
+)
+
+const defaultLiveQueryInterval = 5 * time.Second
+
+type WatchOptions struct {
+	// 连续出错的最大次数，达到后推送错误并结束，为 0 时首次出错即结束，小于 0 时一直重试
+	MaxErrors int
+	// 出错后的退避时间，每次翻倍并加入随机抖动，不超过 MaxBackoff，期间不按 interval 轮询
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// 非空时替代默认的变化判断（比较 JSON 序列化结果的哈希），返回 true 表示未变化
+	Equal func(prev, next any) bool
+}
+
+var DefaultWatchOptions = &WatchOptions{
+	MaxErrors:  5,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+}
+
+// Watch 按 interval 轮询 query，结果变化时推送（首次结果总是推送），上下文结束时关闭 dataChan
+// interval 小于等于 0 时使用 operation 配置的 liveQueryConfig.pollingIntervalSeconds，未配置时为 5s
+func (m *Meta[I, O]) Watch(ctx context.Context, input I, interval time.Duration, client *types.InternalClient, options ...*WatchOptions) (dataChan chan SubscriberData[O], err error) {
+	if m.Type != types.OperationType_QUERY {
+		err = fmt.Errorf("watch only supports query operation, but got [%s]", m.Path)
+		return
+	}
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	if interval <= 0 {
+		interval = liveQueryInterval(m.Path)
+	}
+	watchOptions := DefaultWatchOptions
+	if len(options) > 0 && options[0] != nil {
+		watchOptions = options[0]
+	}
+
+	dataChan = make(chan SubscriberData[O])
+	go func() {
+		defer close(dataChan)
+		var (
+			prev     *O
+			prevHash []byte
+			errTimes int
+		)
+		backoffPolicy := &types.RequestPolicy{Backoff: watchOptions.Backoff, MaxBackoff: watchOptions.MaxBackoff}
+		for {
+			wait := interval
+			data, executeErr := m.ExecuteContext(ctx, input, client)
+			if ctx.Err() != nil {
+				return
+			}
+			if executeErr != nil {
+				if watchOptions.MaxErrors >= 0 && errTimes >= watchOptions.MaxErrors {
+					sendSubscriberData(ctx, dataChan, newSubscriberError[O](executeErr))
+					return
+				}
+				wait = retryBackoff(backoffPolicy, errTimes)
+				errTimes++
+			} else {
+				errTimes = 0
+				if changed, hash := liveQueryChanged(watchOptions, prev, prevHash, data); changed {
+					prev, prevHash = &data, hash
+					if !sendSubscriberData(ctx, dataChan, SubscriberData[O]{Data: data}) {
+						return
+					}
+				}
+			}
+			if sleepContext(ctx, wait) != nil {
+				return
+			}
+		}
+	}()
+	return
+}
+
+// WatchSubscribeFn 用于 customize 中 subscription 字段的 Subscribe，将 query 以轮询方式推送给客户端
+// 字段参数解析为 I，字段的 Resolve 使用 WatchResolve
+func (m *Meta[I, O]) WatchSubscribeFn(interval time.Duration, options ...*WatchOptions) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		grc, args, err := ResolveArgs[I](params)
+		if err != nil {
+			return nil, err
+		}
+		var input I
+		if args != nil {
+			input = *args
+		}
+		dataChan, err := m.Watch(grc.Context, input, interval, grc.InternalClient, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceChan := make(chan interface{})
+		go func() {
+			defer close(sourceChan)
+			for item := range dataChan {
+				var source interface{} = item.Data
+				if item.Err != nil {
+					source = item.Err
+				}
+				select {
+				case sourceChan <- source:
+				case <-grc.Context.Done():
+					return
+				}
+			}
+		}()
+		return sourceChan, nil
+	}
+}
+
+// WatchResolve 返回 WatchSubscribeFn 推送的数据，轮询出错时返回该错误
+func WatchResolve(params graphql.ResolveParams) (interface{}, error) {
+	if err, ok := params.Source.(error); ok {
+		return nil, err
+	}
+	return params.Source, nil
+}
+
+func liveQueryInterval(path string) time.Duration {
+	if api := types.WdgGraphConfig.Api; api != nil {
+		for _, operation := range api.Operations {
+			if operation.Path == path && operation.LiveQueryConfig != nil && operation.LiveQueryConfig.PollingIntervalSeconds > 0 {
+				return time.Duration(operation.LiveQueryConfig.PollingIntervalSeconds) * time.Second
+			}
+		}
+	}
+	return defaultLiveQueryInterval
+}
+
+func liveQueryChanged[O any](options *WatchOptions, prev *O, prevHash []byte, next O) (bool, []byte) {
+	if options.Equal != nil {
+		return prev == nil || !options.Equal(*prev, next), nil
+	}
+	nextBytes, err := utils.MarshalWithoutEscapeHTML(next)
+	if err != nil {
+		return true, nil
+	}
+	nextHash := sha256.Sum256(nextBytes)
+	return prev == nil || !bytes.Equal(prevHash, nextHash[:]), nextHash[:]
+}
@@ -0,0 +1,218 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type NodeState string
+
+const (
+	NodeState_unknown     NodeState = "unknown"
+	NodeState_ready       NodeState = "ready"
+	NodeState_unavailable NodeState = "unavailable"
+)
+
+const nodeReadinessHealthState = "node"
+
+// ErrNodeNotReady 等待 Fireboom 节点就绪超时时返回，可通过 errors.Is 判断
+var ErrNodeNotReady = errors.New("fireboom node is not ready")
+
+type (
+	NodeReadinessOptions struct {
+		// 相对节点地址的探测路径，默认为 /，响应状态码小于 500 即视为就绪
+		ProbePath string
+		// 单次探测超时，默认 2s
+		ProbeTimeout time.Duration
+		// 探测失败后的重试间隔，默认 200ms
+		ProbeInterval time.Duration
+		// WaitForNode 的最长等待时间，默认配置为 5 分钟，为 0 时仅受 ctx 限制
+		WaitTimeout time.Duration
+	}
+	NodeReadinessStatus struct {
+		State       NodeState  `json:"state"`
+		LastProbeAt *time.Time `json:"lastProbeAt,omitempty"`
+		ReadyAt     *time.Time `json:"readyAt,omitempty"`
+		LastError   string     `json:"lastError,omitempty"`
+	}
+	nodeReadiness struct {
+		options     NodeReadinessOptions
+		state       NodeState
+		ready       chan struct{}
+		probing     bool
+		lastProbeAt time.Time
+		readyAt     time.Time
+		lastError   error
+		mutex       sync.Mutex
+	}
+)
+
+var (
+	nodeReadinessService = &nodeReadiness{
+		options: NodeReadinessOptions{
+			ProbePath:     "/",
+			ProbeTimeout:  2 * time.Second,
+			ProbeInterval: 200 * time.Millisecond,
+			WaitTimeout:   5 * time.Minute,
+		},
+		state: NodeState_unknown,
+		ready: make(chan struct{}),
+	}
+	nodeProbeHttpClient = &http.Client{}
+)
+
+func init() {
+	types.AddHealthStateFunc(nodeReadinessHealthState, func() any {
+		return NodeReadinessState()
+	})
+}
+
+// ConfigureNodeReadiness 未设置的选项使用默认值
+func ConfigureNodeReadiness(options NodeReadinessOptions) {
+	nodeReadinessService.mutex.Lock()
+	defer nodeReadinessService.mutex.Unlock()
+	if options.ProbePath == "" {
+		options.ProbePath = "/"
+	}
+	if options.ProbeTimeout <= 0 {
+		options.ProbeTimeout = 2 * time.Second
+	}
+	if options.ProbeInterval <= 0 {
+		options.ProbeInterval = 200 * time.Millisecond
+	}
+	nodeReadinessService.options = options
+}
+
+func NodeReadinessState() NodeReadinessStatus {
+	nodeReadinessService.mutex.Lock()
+	defer nodeReadinessService.mutex.Unlock()
+	status := NodeReadinessStatus{State: nodeReadinessService.state}
+	if !nodeReadinessService.lastProbeAt.IsZero() {
+		lastProbeAt := nodeReadinessService.lastProbeAt
+		status.LastProbeAt = &lastProbeAt
+	}
+	if !nodeReadinessService.readyAt.IsZero() {
+		readyAt := nodeReadinessService.readyAt
+		status.ReadyAt = &readyAt
+	}
+	if nodeReadinessService.lastError != nil {
+		status.LastError = nodeReadinessService.lastError.Error()
+	}
+	return status
+}
+
+// WaitForNode 阻塞直到 Fireboom 节点就绪，超时返回包含最后一次探测错误的 ErrNodeNotReady
+func WaitForNode(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ready, waitTimeout := nodeReadinessService.await()
+	if ready == nil {
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if waitTimeout > 0 {
+		timer := time.NewTimer(waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		if lastError := NodeReadinessState().LastError; lastError != "" {
+			return fmt.Errorf("%w: %s", ErrNodeNotReady, lastError)
+		}
+		return ErrNodeNotReady
+	}
+}
+
+// await 已就绪时返回 nil，否则确保探测进行中并返回就绪通知
+func (r *nodeReadiness) await() (chan struct{}, time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.state == NodeState_ready {
+		return nil, 0
+	}
+	r.startProbe()
+	return r.ready, r.options.WaitTimeout
+}
+
+// observe 连接节点失败时标记为不可用并重新探测，用于感知节点重启
+func (r *nodeReadiness) observe(err error) {
+	var opErr *net.OpError
+	if err == nil || !errors.As(err, &opErr) || opErr.Op != "dial" {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.state == NodeState_ready {
+		r.state, r.ready, r.lastError = NodeState_unavailable, make(chan struct{}), err
+		log.Errorf("fireboom node became unavailable, err: %v", err)
+	}
+	r.startProbe()
+}
+
+// startProbe 需持有锁，同一时间只有一个探测协程
+func (r *nodeReadiness) startProbe() {
+	if r.probing {
+		return
+	}
+	r.probing = true
+	go r.probeUntilReady()
+}
+
+func (r *nodeReadiness) probeUntilReady() {
+	for {
+		r.mutex.Lock()
+		options := r.options
+		r.mutex.Unlock()
+
+		err := probeNode(options)
+		r.mutex.Lock()
+		r.lastProbeAt = time.Now()
+		if err == nil {
+			r.state, r.readyAt, r.lastError, r.probing = NodeState_ready, r.lastProbeAt, nil, false
+			close(r.ready)
+			r.mutex.Unlock()
+			log.Infof("fireboom node is ready")
+			return
+		}
+		r.lastError = err
+		if r.state == NodeState_unknown {
+			r.state = NodeState_unavailable
+		}
+		r.mutex.Unlock()
+		time.Sleep(options.ProbeInterval)
+	}
+}
+
+func probeNode(options NodeReadinessOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), options.ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, types.PrivateNodeUrl+options.ProbePath, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := nodeProbeHttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
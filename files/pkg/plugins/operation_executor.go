@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type (
+	// HttpOperationExecutor 请求 Fireboom 节点执行内部调用，为默认实现
+	HttpOperationExecutor struct{}
+	// MemoryOperationExecutor 将内部调用路由到注册的 Go 函数，未注册的 operation 返回错误，未注册 TransactionEndpointPath 时结束事务视为成功
+	MemoryOperationExecutor struct {
+		operations    map[string]MemoryOperationHandler
+		subscriptions map[string]MemorySubscriptionHandler
+		uploads       map[string]MemoryUploadHandler
+		mutex         sync.RWMutex
+	}
+	// MemoryOperationHandler 返回 types.OperationBodyResponse[any] 时可同时携带 errors
+	MemoryOperationHandler    func(ctx context.Context, client *types.InternalClient, input json.RawMessage) (data any, err error)
+	MemorySubscriptionHandler func(ctx context.Context, client *types.InternalClient, input json.RawMessage, emit func(data any) error) error
+	MemoryUploadHandler       func(ctx context.Context, client *types.InternalClient, request *types.UploadRequest) (types.UploadedFiles, error)
+	// RecordingOperationExecutor 记录经由 Next 执行的内部调用，上传以 s3upload 路径记录为 Execute
+	RecordingOperationExecutor struct {
+		Next     types.OperationExecutor
+		Recorder OperationRecorder
+	}
+	OperationRecorder interface {
+		RecordExecute(path string, input any, response []byte, err error)
+		// RecordSubscribe 返回的函数用于记录每次推送的 data 数据
+		RecordSubscribe(path string, input any) func(event []byte)
+	}
+)
+
+// DefaultOperationExecutor InternalClient 未设置 Executor 时使用
+var DefaultOperationExecutor types.OperationExecutor = &HttpOperationExecutor{}
+
+// fetchOperationExecutor 通过 AsUser/AsService 派生的客户端额外审计每次调用
+func fetchOperationExecutor(client *types.InternalClient) (executor types.OperationExecutor) {
+	executor = DefaultOperationExecutor
+	if client == nil {
+		return
+	}
+	if client.Executor != nil {
+		executor = client.Executor
+	}
+	if client.Impersonation != nil {
+		executor = &impersonatedOperationExecutor{next: executor}
+	}
+	return
+}
+
+func uploadOperationPath(provider string) string {
+	return strings.ReplaceAll(string(types.InternalEndpoint_s3upload), "{provider}", provider)
+}
+
+func (e *HttpOperationExecutor) Execute(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) ([]byte, error) {
+	if graphqlRequest, ok := request.Input.(*GraphqlRequest); ok && request.Path == GraphqlEndpointPath {
+		return requestGraphql(ctx, client, graphqlRequest)
+	}
+	if finishRequest, ok := request.Input.(*TransactionFinishRequest); ok && request.Path == TransactionEndpointPath {
+		return requestTransactionFinish(ctx, client, finishRequest)
+	}
+	return fetchQueryResponse(ctx, client, request.Path, request.Type, request.Input, func() ([]byte, error) {
+		return requestInternalOperation[any](ctx, client, request.Path, request.Type, request.Input)
+	})
+}
+
+func (e *HttpOperationExecutor) Subscribe(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (<-chan *types.SubscriptionEvent, error) {
+	return subscribeNode(ctx, client, request)
+}
+
+func (e *HttpOperationExecutor) Upload(ctx context.Context, _ *types.InternalClient, request *types.UploadRequest) (types.UploadedFiles, error) {
+	return uploadNode(ctx, request)
+}
+
+func NewMemoryOperationExecutor() *MemoryOperationExecutor {
+	return &MemoryOperationExecutor{
+		operations:    make(map[string]MemoryOperationHandler),
+		subscriptions: make(map[string]MemorySubscriptionHandler),
+		uploads:       make(map[string]MemoryUploadHandler),
+	}
+}
+
+// HandleMemoryOperation 以 meta 的入参和出参类型注册 query 或 mutation 的处理函数
+func HandleMemoryOperation[I, O any](e *MemoryOperationExecutor, meta *Meta[I, O], handler func(context.Context, *types.InternalClient, I) (O, error)) *MemoryOperationExecutor {
+	return e.HandleOperation(meta.Path, func(ctx context.Context, client *types.InternalClient, input json.RawMessage) (data any, err error) {
+		var typedInput I
+		if err = json.Unmarshal(input, &typedInput); err != nil {
+			return
+		}
+		return handler(ctx, client, typedInput)
+	})
+}
+
+// HandleMemorySubscription 以 subscriber 的入参和出参类型注册订阅的处理函数
+func HandleMemorySubscription[I, O any](e *MemoryOperationExecutor, subscriber *Subscriber[I, O], handler func(context.Context, *types.InternalClient, I, func(O) error) error) *MemoryOperationExecutor {
+	return e.HandleSubscription(subscriber.Path, func(ctx context.Context, client *types.InternalClient, input json.RawMessage, emit func(any) error) error {
+		var typedInput I
+		if err := json.Unmarshal(input, &typedInput); err != nil {
+			return err
+		}
+		return handler(ctx, client, typedInput, func(data O) error { return emit(data) })
+	})
+}
+
+func (e *MemoryOperationExecutor) HandleOperation(path string, handler MemoryOperationHandler) *MemoryOperationExecutor {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.operations[path] = handler
+	return e
+}
+
+func (e *MemoryOperationExecutor) HandleSubscription(path string, handler MemorySubscriptionHandler) *MemoryOperationExecutor {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.subscriptions[path] = handler
+	return e
+}
+
+// HandleUpload provider 对应 UploadClient 的名称
+func (e *MemoryOperationExecutor) HandleUpload(provider string, handler MemoryUploadHandler) *MemoryOperationExecutor {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.uploads[provider] = handler
+	return e
+}
+
+func (e *MemoryOperationExecutor) Execute(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (respBytes []byte, err error) {
+	e.mutex.RLock()
+	handler, ok := e.operations[request.Path]
+	e.mutex.RUnlock()
+	if !ok && request.Path == TransactionEndpointPath {
+		// 未注册时视为节点成功结束事务
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("operation [%s] is not registered", request.Path)
+		return
+	}
+
+	inputBytes, err := utils.MarshalWithoutEscapeHTML(request.Input)
+	if err != nil {
+		return
+	}
+	data, err := handler(ctx, client, inputBytes)
+	if err != nil {
+		return
+	}
+	if resp, ok := data.(types.OperationBodyResponse[any]); ok {
+		return utils.MarshalWithoutEscapeHTML(resp)
+	}
+	return utils.MarshalWithoutEscapeHTML(types.OperationBodyResponse[any]{Data: data})
+}
+
+// Subscribe handler 返回后关闭通道，返回错误时先推送携带该错误的事件
+func (e *MemoryOperationExecutor) Subscribe(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (<-chan *types.SubscriptionEvent, error) {
+	e.mutex.RLock()
+	handler, ok := e.subscriptions[request.Path]
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("subscription [%s] is not registered", request.Path)
+	}
+
+	inputBytes, err := utils.MarshalWithoutEscapeHTML(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan *types.SubscriptionEvent)
+	go func() {
+		defer close(events)
+		handleErr := handler(ctx, client, inputBytes, func(data any) error {
+			dataBytes, marshalErr := utils.MarshalWithoutEscapeHTML(data)
+			if marshalErr != nil {
+				return marshalErr
+			}
+			if !sendSubscriptionEvent(ctx, events, &types.SubscriptionEvent{Data: dataBytes}) {
+				return ctx.Err()
+			}
+			return nil
+		})
+		if handleErr != nil && ctx.Err() == nil {
+			sendSubscriptionEvent(ctx, events, &types.SubscriptionEvent{Err: handleErr})
+		}
+	}()
+	return events, nil
+}
+
+func (e *MemoryOperationExecutor) Upload(ctx context.Context, client *types.InternalClient, request *types.UploadRequest) (types.UploadedFiles, error) {
+	e.mutex.RLock()
+	handler, ok := e.uploads[request.Provider]
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("upload provider [%s] is not registered", request.Provider)
+	}
+	return handler(ctx, client, request)
+}
+
+// NewRecordingOperationExecutor next 为 nil 时使用 DefaultOperationExecutor
+func NewRecordingOperationExecutor(next types.OperationExecutor, recorder OperationRecorder) *RecordingOperationExecutor {
+	if next == nil {
+		next = DefaultOperationExecutor
+	}
+	return &RecordingOperationExecutor{Next: next, Recorder: recorder}
+}
+
+func (e *RecordingOperationExecutor) Execute(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) ([]byte, error) {
+	respBytes, err := e.Next.Execute(ctx, client, request)
+	e.Recorder.RecordExecute(request.Path, request.Input, respBytes, err)
+	return respBytes, err
+}
+
+func (e *RecordingOperationExecutor) Subscribe(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (<-chan *types.SubscriptionEvent, error) {
+	recordEvent := e.Recorder.RecordSubscribe(request.Path, request.Input)
+	events, err := e.Next.Subscribe(ctx, client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	recordedEvents := make(chan *types.SubscriptionEvent)
+	go func() {
+		defer close(recordedEvents)
+		for event := range events {
+			if event.Err == nil {
+				recordEvent(event.Data)
+			}
+			if !sendSubscriptionEvent(ctx, recordedEvents, event) {
+				return
+			}
+		}
+	}()
+	return recordedEvents, nil
+}
+
+func (e *RecordingOperationExecutor) Upload(ctx context.Context, client *types.InternalClient, request *types.UploadRequest) (uploadResp types.UploadedFiles, err error) {
+	uploadResp, err = e.Next.Upload(ctx, client, request)
+	var respBytes []byte
+	if err == nil {
+		respBytes, _ = utils.MarshalWithoutEscapeHTML(uploadResp)
+	}
+	e.Recorder.RecordExecute(uploadOperationPath(request.Provider), request, respBytes, err)
+	return
+}
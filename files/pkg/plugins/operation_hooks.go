@@ -30,18 +30,42 @@ func MakeDataAnyMap(data any) map[string]any {
 }
 
 func (m *Meta[I, O]) RegisterHook(hook types.MiddlewareHook, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
-	registerHook[I, O](m.Path, hook, resolve)
+	registerHook[I, O](m.Path, operationAuditTargets[m.Type], hook, defaultHookPriority, resolve)
+}
+
+// RegisterHookWithPriority 同一个钩子可注册多个处理函数，priority 越大越先执行，前一个的输出作为后一个的输入
+func (m *Meta[I, O]) RegisterHookWithPriority(hook types.MiddlewareHook, priority int, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
+	registerHook[I, O](m.Path, operationAuditTargets[m.Type], hook, priority, resolve)
 }
 
 func (m *Subscriber[I, O]) RegisterHook(hook types.MiddlewareHook, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
-	registerHook[I, O](m.Path, hook, resolve)
+	registerHook[I, O](m.Path, AuditTarget_subscription, hook, defaultHookPriority, resolve)
+}
+
+func (m *Subscriber[I, O]) RegisterHookWithPriority(hook types.MiddlewareHook, priority int, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
+	registerHook[I, O](m.Path, AuditTarget_subscription, hook, priority, resolve)
 }
 
-func registerHook[I, O any](path string, hook types.MiddlewareHook, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
+var operationAuditTargets = map[types.OperationType]AuditTarget{
+	types.OperationType_QUERY:        AuditTarget_query,
+	types.OperationType_MUTATION:     AuditTarget_mutation,
+	types.OperationType_SUBSCRIPTION: AuditTarget_subscription,
+}
+
+func registerHook[I, O any](path string, auditTarget AuditTarget, hook types.MiddlewareHook, priority int, resolve func(*types.HookRequest, *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) {
+	apiPath := fmt.Sprintf("/operation/%s/%s", path, hook)
+	chain, created, err := addOperationHookHandler[I, O](apiPath, priority, resolve)
+	if err != nil {
+		hookRegisterErrors = append(hookRegisterErrors, err)
+		return
+	}
+	if !created {
+		return
+	}
+
 	types.AddEchoRouterFunc(func(e *echo.Echo) {
-		apiPath := fmt.Sprintf("/operation/%s/%s", path, hook)
-		e.Logger.Debugf(`Registered operationHook [%s]`, apiPath)
-		e.POST(apiPath, buildOperationHook(path, hook, resolve))
+		e.Logger.Debugf(`Registered operationHook [%s] with %d handler(s)`, apiPath, len(chain.handlers))
+		e.POST(apiPath, buildOperationHook(path, auditTarget, hook, chain.resolve))
 	})
 }
 
@@ -82,7 +106,7 @@ var resolveRewriteFuncs = map[types.MiddlewareHook]func([]byte, []byte) []byte{
 	},
 }
 
-func buildOperationHook[I, O any](operationPath string, hook types.MiddlewareHook,
+func buildOperationHook[I, O any](operationPath string, auditTarget AuditTarget, hook types.MiddlewareHook,
 	resolve func(hook *types.HookRequest, body *types.OperationBody[I, O]) (*types.OperationBody[I, O], error)) echo.HandlerFunc {
 	return func(c echo.Context) (err error) {
 		c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
@@ -101,24 +125,58 @@ func buildOperationHook[I, O any](operationPath string, hook types.MiddlewareHoo
 			return
 		}
 
+		var outBytes []byte
+		auditRecord := newAuditRecord(hookRequest, auditTarget, operationPath, hook, []byte(gjson.GetBytes(bodyBytes, "input").Raw))
+		defer func() { auditRecord.finish([]byte(gjson.GetBytes(outBytes, "response").Raw), err) }()
+
 		in.Op = operationPath
 		in.Hook = hook
 		in.SetClientRequestHeaders = HeadersToObject(c.Request().Header)
-		out, err := resolve(hookRequest, in)
-		if err != nil {
-			return err
+		var out *types.OperationBody[I, O]
+		switch toggleMode, toggle := hookToggleMode(c.Path(), hookRequest.User); toggleMode {
+		case ToggleMode_disabled:
+			c.Logger().Debugf("operationHook [%s] skipped by toggle", c.Path())
+			// 审计记录实际返回的原始响应
+			if outBytes, err = utils.MarshalWithoutEscapeHTML(in); err != nil {
+				return
+			}
+			return c.JSONBlob(http.StatusOK, outBytes)
+		case ToggleMode_mock:
+			out = in
+			out.Response = &types.OperationBodyResponse[O]{}
+			if len(toggle.Mock) > 0 {
+				if err = json.Unmarshal(toggle.Mock, &out.Response.Data); err != nil {
+					return
+				}
+			}
+		default:
+			if out, err = resolve(hookRequest, in); err != nil {
+				return err
+			}
 		}
-		if out == nil {
-			return c.JSON(http.StatusOK, in)
+		responseMaskRules := fetchResponseMaskRules[O](operationPath, hook)
+		// 钩子返回 nil 时原样返回入参，审计同样记录其响应
+		passthrough := out == nil
+		if passthrough {
+			out = in
+		}
+		if clientResponse := hookRequest.ClientResponse(); clientResponse != nil {
+			out.SetClientResponse = out.SetClientResponse.Merge(clientResponse)
 		}
 
-		outBytes, err := utils.MarshalWithoutEscapeHTML(out)
+		outBytes, err = utils.MarshalWithoutEscapeHTML(out)
 		if err != nil {
 			return err
 		}
+		if passthrough && len(responseMaskRules) == 0 {
+			return c.JSONBlob(http.StatusOK, outBytes)
+		}
 		if rewriteFunc, ok := resolveRewriteFuncs[hook]; ok {
 			outBytes = rewriteFunc(bodyBytes, outBytes)
 		}
+		if len(responseMaskRules) > 0 {
+			outBytes = maskOperationResponse(responseMaskRules, hookRequest.User, outBytes)
+		}
 		return c.JSONBlob(http.StatusOK, outBytes)
 	}
 }
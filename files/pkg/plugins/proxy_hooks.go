@@ -4,6 +4,7 @@ import (
 	"custom-go/pkg/types"
 	"custom-go/pkg/utils"
 	"encoding/json"
+	"fmt"
 	"github.com/labstack/echo/v4"
 	"net/http"
 	"os"
@@ -19,7 +20,7 @@ func RegisterProxyHook(hookFunc httpProxyHookFunction, operationType ...types.Op
 
 	types.AddEchoRouterFunc(func(e *echo.Echo) {
 		e.Logger.Debugf(`Registered proxyFunction [%s]`, apiPath)
-		e.POST(apiPath, buildProxyFunc(hookFunc))
+		e.POST(apiPath, buildProxyFunc(callerName, hookFunc))
 	})
 
 	types.AddHealthFunc(func(e *echo.Echo, report *types.HealthReportLock) {
@@ -56,7 +57,7 @@ func RegisterProxyHook(hookFunc httpProxyHookFunction, operationType ...types.Op
 	})
 }
 
-func buildProxyFunc(proxyHook httpProxyHookFunction) echo.HandlerFunc {
+func buildProxyFunc(proxyName string, proxyHook httpProxyHookFunction) echo.HandlerFunc {
 	return func(c echo.Context) (err error) {
 		brc := c.(*types.HttpTransportHookRequest)
 
@@ -66,10 +67,24 @@ func buildProxyFunc(proxyHook httpProxyHookFunction) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
-		newResp, err := proxyHook(brc, &reqBody)
+		var newResp *types.WunderGraphResponse
+		requestBytes, _ := json.Marshal(reqBody.Request)
+		auditRecord := newAuditRecord(brc, AuditTarget_proxy, proxyName, types.MiddlewareHook(types.HookParent_proxy), requestBytes)
+		defer func() {
+			var responseBytes []byte
+			if newResp != nil {
+				responseBytes, _ = json.Marshal(newResp)
+			}
+			auditRecord.finish(responseBytes, err)
+		}()
+
+		newResp, err = proxyHook(brc, &reqBody)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		if clientResponse := brc.ClientResponse(); clientResponse != nil && newResp != nil {
+			mergeClientResponse(c.Response().Header(), newResp, clientResponse)
+		}
 		resp := types.MiddlewareHookResponse{
 			Op:   reqBody.Name,
 			Hook: types.MiddlewareHook(types.HookParent_proxy),
@@ -80,3 +95,20 @@ func buildProxyFunc(proxyHook httpProxyHookFunction) echo.HandlerFunc {
 		return c.JSON(http.StatusOK, resp)
 	}
 }
+
+// mergeClientResponse 代理响应头每个键只能保存单个值，cookie 逐个写入钩子响应的 Set-Cookie 头部，避免合并后无法解析
+func mergeClientResponse(header http.Header, resp *types.WunderGraphResponse, clientResponse *types.ClientResponse) {
+	if clientResponse.StatusCode != 0 {
+		resp.StatusCode = int64(clientResponse.StatusCode)
+		resp.Status = fmt.Sprintf("%d %s", clientResponse.StatusCode, http.StatusText(clientResponse.StatusCode))
+	}
+	if resp.Headers == nil {
+		resp.Headers = types.RequestHeaders{}
+	}
+	for k, v := range clientResponse.Headers {
+		resp.Headers[k] = v
+	}
+	for _, cookie := range clientResponse.Cookies {
+		header.Add(echo.HeaderSetCookie, cookie)
+	}
+}
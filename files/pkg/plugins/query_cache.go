@@ -0,0 +1,212 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"errors"
+	"github.com/tidwall/gjson"
+	"golang.org/x/exp/slices"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultQueryCacheMaxEntries = 1000
+
+type (
+	// QueryCacheOptions 仅对 query 生效，按 operation 路径、入参、用户身份和 ExtraHeaders 区分，事务内的调用不使用
+	QueryCacheOptions struct {
+		// 合并并发的相同调用，只请求一次节点
+		Coalesce bool
+		// 无错误响应的缓存时间，为 0 时不缓存
+		TTL time.Duration
+		// 最大缓存条目数，默认 1000
+		MaxEntries int
+	}
+	QueryCacheMetrics struct {
+		Hits      int64 `json:"hits"`
+		Misses    int64 `json:"misses"`
+		Coalesced int64 `json:"coalesced"`
+		Evictions int64 `json:"evictions"`
+	}
+	queryCache struct {
+		options  *QueryCacheOptions
+		metrics  QueryCacheMetrics
+		mutex    sync.Mutex
+		entries  map[string]*queryCacheEntry
+		inflight map[string]*queryCall
+	}
+	queryCacheEntry struct {
+		content  []byte
+		expireAt time.Time
+	}
+	queryCall struct {
+		done    chan struct{}
+		content []byte
+		err     error
+	}
+)
+
+var queryCaches = &sync.Map{}
+
+// SetQueryCacheOptions 为 query 开启调用合并和缓存，options 为 nil 时关闭并清空缓存
+func SetQueryCacheOptions(operationPath string, options *QueryCacheOptions) {
+	if options == nil {
+		queryCaches.Delete(operationPath)
+		return
+	}
+	queryCaches.Store(operationPath, &queryCache{
+		options:  options,
+		entries:  make(map[string]*queryCacheEntry),
+		inflight: make(map[string]*queryCall),
+	})
+}
+
+// WithCache 等同于 SetQueryCacheOptions(m.Path, options)，仅对 query 生效
+func (m *Meta[I, O]) WithCache(options *QueryCacheOptions) *Meta[I, O] {
+	SetQueryCacheOptions(m.Path, options)
+	return m
+}
+
+func InvalidateQueryCache(operationPath string) {
+	if cache := fetchQueryCache(operationPath); cache != nil {
+		cache.mutex.Lock()
+		cache.entries = make(map[string]*queryCacheEntry)
+		cache.mutex.Unlock()
+	}
+}
+
+func QueryCacheStats() map[string]QueryCacheMetrics {
+	result := make(map[string]QueryCacheMetrics)
+	queryCaches.Range(func(key, value any) bool {
+		cache := value.(*queryCache)
+		result[key.(string)] = QueryCacheMetrics{
+			Hits:      atomic.LoadInt64(&cache.metrics.Hits),
+			Misses:    atomic.LoadInt64(&cache.metrics.Misses),
+			Coalesced: atomic.LoadInt64(&cache.metrics.Coalesced),
+			Evictions: atomic.LoadInt64(&cache.metrics.Evictions),
+		}
+		return true
+	})
+	return result
+}
+
+func fetchQueryCache(operationPath string) *queryCache {
+	if value, ok := queryCaches.Load(operationPath); ok {
+		return value.(*queryCache)
+	}
+	return nil
+}
+
+// fetchQueryResponse 对开启缓存的 query 合并调用并缓存无错误的响应
+func fetchQueryResponse(ctx context.Context, client *types.InternalClient, path string, operationType types.OperationType, input any, fetch func() ([]byte, error)) ([]byte, error) {
+	cache := fetchQueryCache(path)
+	if cache == nil || operationType != types.OperationType_QUERY {
+		return fetch()
+	}
+
+	// 事务内的查询需读取事务中未提交的数据，不使用缓存也不与事务外的调用合并
+	if client != nil && client.ExtraHeaders.GetIgnoreCase(string(types.TransactionHeader_X_Transaction_Id)) != "" {
+		return fetch()
+	}
+	key, err := queryCacheKey(client, input)
+	if err != nil {
+		return fetch()
+	}
+
+	cache.mutex.Lock()
+	if entry, ok := cache.entries[key]; ok && time.Now().Before(entry.expireAt) {
+		cache.mutex.Unlock()
+		atomic.AddInt64(&cache.metrics.Hits, 1)
+		return entry.content, nil
+	}
+	if call, ok := cache.inflight[key]; ok && cache.options.Coalesce {
+		cache.mutex.Unlock()
+		atomic.AddInt64(&cache.metrics.Coalesced, 1)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// 发起者的上下文取消时自行请求
+		if errors.Is(call.err, context.Canceled) && ctx.Err() == nil {
+			return fetch()
+		}
+		return call.content, call.err
+	}
+
+	atomic.AddInt64(&cache.metrics.Misses, 1)
+	call := &queryCall{done: make(chan struct{})}
+	if cache.options.Coalesce {
+		cache.inflight[key] = call
+	}
+	cache.mutex.Unlock()
+
+	call.content, call.err = fetch()
+	cache.mutex.Lock()
+	if cache.inflight[key] == call {
+		delete(cache.inflight, key)
+	}
+	if call.err == nil && cache.options.TTL > 0 && len(gjson.GetBytes(call.content, "errors").Array()) == 0 {
+		cache.store(key, call.content)
+	}
+	cache.mutex.Unlock()
+	close(call.done)
+	return call.content, call.err
+}
+
+// queryCacheKey 由完整的用户身份（userId、provider、roles）、除请求 id 外的 ExtraHeaders 和入参组成
+func queryCacheKey(client *types.InternalClient, input any) (string, error) {
+	var identity struct {
+		UserId   string               `json:"userId,omitempty"`
+		Provider string               `json:"provider,omitempty"`
+		Roles    []string             `json:"roles,omitempty"`
+		Headers  types.RequestHeaders `json:"headers,omitempty"`
+		Input    any                  `json:"input"`
+	}
+	identity.Input = input
+	if client != nil {
+		if client.BaseRequestBodyWg != nil && client.User != nil {
+			identity.UserId, identity.Provider = client.User.UserId, client.User.Provider
+			identity.Roles = slices.Clone(client.User.Roles)
+			sort.Strings(identity.Roles)
+		}
+		identity.Headers = make(types.RequestHeaders, len(client.ExtraHeaders))
+		for k, v := range client.ExtraHeaders {
+			if !strings.EqualFold(k, string(types.InternalHeader_X_Request_Id)) {
+				identity.Headers[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+	}
+	keyBytes, err := utils.MarshalWithoutEscapeHTML(identity)
+	return string(keyBytes), err
+}
+
+// store 需持有锁，超出容量时先淘汰过期条目，仍不足时随机淘汰
+func (c *queryCache) store(key string, content []byte) {
+	maxEntries := c.options.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultQueryCacheMaxEntries
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxEntries {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expireAt) {
+				delete(c.entries, k)
+				atomic.AddInt64(&c.metrics.Evictions, 1)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < maxEntries {
+				break
+			}
+			delete(c.entries, k)
+			atomic.AddInt64(&c.metrics.Evictions, 1)
+		}
+	}
+	c.entries[key] = &queryCacheEntry{content: content, expireAt: time.Now().Add(c.options.TTL)}
+}
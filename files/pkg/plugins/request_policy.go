@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"errors"
+	"golang.org/x/exp/slices"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultRequestPolicy 单次请求 30 秒超时，默认不重试，设置 MaxRetries 后对 query 和 subscription 在网关类错误时重试
+	DefaultRequestPolicy = &types.RequestPolicy{
+		Timeout:    30 * time.Second,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+	}
+	defaultRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+	operationPolicies      = make(map[string]*types.RequestPolicy)
+	operationPoliciesMutex sync.RWMutex
+
+	// internalHttpClient 请求 Fireboom 节点专用，复用连接
+	internalHttpClient = &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}}
+)
+
+// SetOperationRequestPolicy 为指定 operation 设置策略，优先于 InternalClient.Policy 和 DefaultRequestPolicy
+func SetOperationRequestPolicy(operationPath string, policy *types.RequestPolicy) {
+	operationPoliciesMutex.Lock()
+	defer operationPoliciesMutex.Unlock()
+	operationPolicies[operationPath] = policy
+}
+
+func fetchRequestPolicy(client *types.InternalClient, path string) *types.RequestPolicy {
+	operationPoliciesMutex.RLock()
+	policy, ok := operationPolicies[path]
+	operationPoliciesMutex.RUnlock()
+	if ok && policy != nil {
+		return policy
+	}
+	if client != nil && client.Policy != nil {
+		return client.Policy
+	}
+	if DefaultRequestPolicy != nil {
+		return DefaultRequestPolicy
+	}
+	return &types.RequestPolicy{}
+}
+
+func retryAllowed(policy *types.RequestPolicy, operationType types.OperationType) bool {
+	return policy.MaxRetries > 0 && (operationType != types.OperationType_MUTATION || policy.RetryMutation)
+}
+
+func retryRequired(policy *types.RequestPolicy, resp *http.Response, err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(resp, err)
+	}
+	if err != nil {
+		// 上层上下文结束或熔断时不再重试，单次请求超时可以重试
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, ErrCircuitOpen)
+	}
+	retryStatusCodes := policy.RetryStatusCodes
+	if len(retryStatusCodes) == 0 {
+		retryStatusCodes = defaultRetryStatusCodes
+	}
+	return slices.Contains(retryStatusCodes, resp.StatusCode)
+}
+
+// retryBackoff 指数退避并使用完全随机抖动
+func retryBackoff(policy *types.RequestPolicy, attempt int) time.Duration {
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		return 0
+	}
+	for i := 0; i < attempt && (policy.MaxBackoff <= 0 || backoff < policy.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff)) + 1)
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancelReadCloser 响应体关闭时释放单次请求的超时上下文
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
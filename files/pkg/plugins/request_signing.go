@@ -0,0 +1,257 @@
+package plugins
+
+import (
+	"custom-go/pkg/signing"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"github.com/labstack/gommon/log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+type (
+	// RequestSigningConfiguration 以数据源 id 为键，按数据源 baseUrl 的协议、主机和路径段匹配上游请求
+	RequestSigningConfiguration struct {
+		Datasources map[string]*signing.Config `json:"datasources"`
+	}
+	RequestSigningOptions struct {
+		// 监听的 RequestSigningConfiguration JSON 文件，文件修改后自动生效，数据源 id 相同时以 Datasources 为准
+		File        string
+		Datasources map[string]*signing.Config
+	}
+)
+
+type (
+	requestSigningTable struct {
+		fileConfig *RequestSigningConfiguration
+		routes     []*requestSigningRoute
+	}
+	requestSigningRoute struct {
+		datasource string
+		scheme     string
+		host       string
+		// 不含末尾的 /，按路径段匹配
+		pathPrefix string
+		signer     signing.Signer
+		err        error
+	}
+)
+
+var (
+	requestSigningOptions RequestSigningOptions
+	requestSigningCache   *requestSigningTable
+	// 每次 ConfigureRequestSigning 后递增，避免以旧配置构建的签名表覆盖新配置
+	requestSigningVersion int
+	requestSigningMutex   sync.RWMutex
+)
+
+// ConfigureRequestSigning 需在注册全局钩子前调用，在 onOriginRequest 中对匹配数据源的请求签名，
+// 签名在自定义的 OnOriginRequest 之后进行且不受钩子开关影响，数据源需开启 onOriginRequest 钩子
+func ConfigureRequestSigning(options RequestSigningOptions) (err error) {
+	for datasource, config := range options.Datasources {
+		if _, err = signing.NewSigner(config); err != nil {
+			log.Errorf("invalid request signing for datasource [%s], err: %v", datasource, err)
+			return
+		}
+	}
+
+	requestSigningMutex.Lock()
+	defer requestSigningMutex.Unlock()
+	requestSigningOptions, requestSigningCache = options, nil
+	requestSigningVersion++
+	return
+}
+
+// SignOriginRequest 用于 HttpTransportRouter 自定义规则，可注册在 BeforeOriginRequest 或 OnOriginRequest
+func SignOriginRequest(signer signing.Signer) TransportRequestHandler {
+	return func(_ *types.HttpTransportHookRequest, body *HttpTransportBody) (*types.WunderGraphRequest, error) {
+		if body.Request == nil {
+			return nil, nil
+		}
+		return signRequestCopy(signer, body.Request)
+	}
+}
+
+func requestSigningEnabled() bool {
+	requestSigningMutex.RLock()
+	defer requestSigningMutex.RUnlock()
+	return requestSigningOptions.File != "" || len(requestSigningOptions.Datasources) > 0
+}
+
+// signOriginRequest 对匹配数据源的请求签名，未匹配时返回 nil
+func signOriginRequest(body *HttpTransportBody) (result *types.WunderGraphRequest, err error) {
+	if body.Request == nil || !requestSigningEnabled() {
+		return
+	}
+	route := matchRequestSigning(body.Request.RequestURI)
+	if route == nil {
+		return
+	}
+
+	if err = route.err; err == nil {
+		result, err = signRequestCopy(route.signer, body.Request)
+	}
+	if err != nil {
+		log.Errorf("sign request for datasource [%s] failed, err: %v", route.datasource, err)
+	}
+	return
+}
+
+func signRequestCopy(signer signing.Signer, req *types.WunderGraphRequest) (*types.WunderGraphRequest, error) {
+	signedReq := *req
+	signedReq.Headers = make(types.RequestHeaders, len(req.Headers))
+	for k, v := range req.Headers {
+		signedReq.Headers[k] = v
+	}
+	if err := signer.Sign(&signedReq); err != nil {
+		return nil, err
+	}
+	return &signedReq, nil
+}
+
+// fetchRequestSigningRoutes 仅在配置文件变化或重新配置后重建签名表
+func fetchRequestSigningRoutes() []*requestSigningRoute {
+	requestSigningMutex.RLock()
+	options, cache, version := requestSigningOptions, requestSigningCache, requestSigningVersion
+	requestSigningMutex.RUnlock()
+
+	var fileConfig *RequestSigningConfiguration
+	if options.File != "" && !utils.NotExistFile(options.File) {
+		var err error
+		fileConfig, err = utils.ParseAndCacheFile(options.File, func(content []byte) (config *RequestSigningConfiguration, err error) {
+			config = &RequestSigningConfiguration{}
+			err = json.Unmarshal(content, config)
+			return
+		})
+		if err != nil {
+			log.Errorf("read request signing [%s] failed, err: %v", options.File, err.Error())
+		}
+	}
+	if cache != nil && cache.fileConfig == fileConfig {
+		return cache.routes
+	}
+
+	configs := make(map[string]*signing.Config, len(options.Datasources))
+	if fileConfig != nil {
+		for datasource, item := range fileConfig.Datasources {
+			if item != nil {
+				configs[datasource] = item
+			}
+		}
+	}
+	for datasource, item := range options.Datasources {
+		configs[datasource] = item
+	}
+	cache = &requestSigningTable{fileConfig: fileConfig, routes: buildRequestSigningRoutes(configs)}
+
+	requestSigningMutex.Lock()
+	if requestSigningVersion == version {
+		requestSigningCache = cache
+	}
+	requestSigningMutex.Unlock()
+	return cache.routes
+}
+
+func buildRequestSigningRoutes(configs map[string]*signing.Config) (routes []*requestSigningRoute) {
+	if len(configs) == 0 {
+		return
+	}
+	for datasource, baseUrls := range datasourceBaseUrls() {
+		config, ok := configs[datasource]
+		if !ok {
+			continue
+		}
+		signer, signerErr := signing.NewSigner(config)
+		for _, baseUrl := range baseUrls {
+			parsedUrl, err := url.Parse(baseUrl)
+			if err != nil || parsedUrl.Host == "" {
+				log.Warnf("ignore invalid baseUrl [%s] of datasource [%s] for request signing", baseUrl, datasource)
+				continue
+			}
+			routes = append(routes, &requestSigningRoute{
+				datasource: datasource,
+				scheme:     strings.ToLower(parsedUrl.Scheme),
+				host:       canonicalUrlHost(parsedUrl),
+				pathPrefix: strings.TrimSuffix(parsedUrl.EscapedPath(), "/"),
+				signer:     signer,
+				err:        signerErr,
+			})
+		}
+	}
+	return
+}
+
+// matchRequestSigning 协议和主机（含端口）需完全一致，路径按段前缀匹配，多个匹配时取最长的前缀
+func matchRequestSigning(requestUrl string) (matched *requestSigningRoute) {
+	routes := fetchRequestSigningRoutes()
+	if len(routes) == 0 {
+		return
+	}
+	parsedUrl, err := url.Parse(requestUrl)
+	if err != nil {
+		return
+	}
+
+	scheme, host, requestPath := strings.ToLower(parsedUrl.Scheme), canonicalUrlHost(parsedUrl), parsedUrl.EscapedPath()
+	for _, route := range routes {
+		if route.scheme != scheme || route.host != host {
+			continue
+		}
+		if route.pathPrefix != "" && requestPath != route.pathPrefix && !strings.HasPrefix(requestPath, route.pathPrefix+"/") {
+			continue
+		}
+		if matched == nil || len(route.pathPrefix) > len(matched.pathPrefix) {
+			matched = route
+		}
+	}
+	return
+}
+
+// canonicalUrlHost 主机名小写并去掉默认端口
+func canonicalUrlHost(parsedUrl *url.URL) string {
+	host, port := strings.ToLower(parsedUrl.Hostname()), parsedUrl.Port()
+	if port == "" || parsedUrl.Scheme == "http" && port == "80" || parsedUrl.Scheme == "https" && port == "443" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// datasourceBaseUrls 返回 REST 和 GraphQL 数据源的 baseUrl（未配置时取 url 中模板变量之前的部分）
+func datasourceBaseUrls() map[string][]string {
+	result := make(map[string][]string)
+	api := types.WdgGraphConfig.Api
+	if api == nil || api.EngineConfiguration == nil {
+		return result
+	}
+
+	for _, datasource := range api.EngineConfiguration.DatasourceConfigurations {
+		fetches := make([]*types.FetchConfiguration, 0, len(datasource.CustomRestMap)+2)
+		if datasource.CustomRest != nil {
+			fetches = append(fetches, datasource.CustomRest.Fetch)
+		}
+		if datasource.CustomGraphql != nil {
+			fetches = append(fetches, datasource.CustomGraphql.Fetch)
+		}
+		for _, item := range datasource.CustomRestMap {
+			if item != nil {
+				fetches = append(fetches, item.Fetch)
+			}
+		}
+		for _, fetch := range fetches {
+			if fetch == nil {
+				continue
+			}
+			baseUrl := types.GetConfigurationVal(fetch.BaseUrl)
+			if baseUrl == "" {
+				baseUrl, _, _ = strings.Cut(types.GetConfigurationVal(fetch.Url), "{{")
+			}
+			if baseUrl != "" {
+				result[datasource.Id] = append(result[datasource.Id], baseUrl)
+			}
+		}
+	}
+	return result
+}
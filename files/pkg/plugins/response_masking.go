@@ -0,0 +1,330 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/cast"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/exp/slices"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type MaskAction string
+
+const (
+	MaskAction_mask   MaskAction = "mask"
+	MaskAction_hash   MaskAction = "hash"
+	MaskAction_remove MaskAction = "remove"
+)
+
+const (
+	maskStructTag        = "mask"
+	maskTagExemptPrefix  = "exempt="
+	maskDefaultCharacter = "*"
+	maskArrayWildcard    = "#"
+	maskResponseDataPath = "response.data"
+)
+
+type (
+	MaskRule struct {
+		// 相对于响应 data 的字段路径，以 . 分隔，数组会自动展开，也可用 # 显式表示数组元素
+		Path   string     `json:"path"`
+		Action MaskAction `json:"action"`
+		// 用户拥有其中任一角色时不处理
+		ExemptRoles []string `json:"exemptRoles,omitempty"`
+		// 用户自定义声明满足其中任一键值时不处理，声明为数组时包含该值即可
+		ExemptClaims map[string]any `json:"exemptClaims,omitempty"`
+		// mask 时保留的首尾字符数
+		KeepPrefix int `json:"keepPrefix,omitempty"`
+		KeepSuffix int `json:"keepSuffix,omitempty"`
+	}
+	// MaskConfiguration 以 operation 路径（函数为函数名）为键配置脱敏规则
+	MaskConfiguration struct {
+		Operations map[string][]*MaskRule `json:"operations"`
+	}
+)
+
+var (
+	maskHooks        = []types.MiddlewareHook{types.MiddlewareHook_mutatingPostResolve, types.MiddlewareHook_customResolve, types.MiddlewareHook_mockResolve, types.MiddlewareHook(types.HookParent_function)}
+	maskRules        = make(map[string][]*MaskRule)
+	maskRulesMutex   sync.RWMutex
+	maskConfigFile   string
+	maskTypeRulesMap = &sync.Map{}
+)
+
+// AddResponseMaskRules 为指定 operation 添加脱敏规则，仅在 mutatingPostResolve/customResolve/mockResolve 钩子和函数的返回中生效
+// 脱敏在钩子路由中进行，operation 未注册上述钩子时规则不生效
+func AddResponseMaskRules(operationPath string, rules ...*MaskRule) {
+	maskRulesMutex.Lock()
+	defer maskRulesMutex.Unlock()
+	maskRules[operationPath] = append(maskRules[operationPath], rules...)
+}
+
+// SetResponseMaskFile 从 JSON 文件读取 MaskConfiguration，文件修改后自动生效，生效范围同 AddResponseMaskRules
+func SetResponseMaskFile(filepath string) {
+	maskRulesMutex.Lock()
+	defer maskRulesMutex.Unlock()
+	maskConfigFile = filepath
+}
+
+// fetchResponseMaskRules 钩子不在 maskHooks 中时返回空，配置文件仅在修改后重新解析
+func fetchResponseMaskRules[O any](operationPath string, hook types.MiddlewareHook) (rules []*MaskRule) {
+	if !slices.Contains(maskHooks, hook) {
+		return
+	}
+
+	maskRulesMutex.RLock()
+	rules = append(rules, maskRules[operationPath]...)
+	configFile := maskConfigFile
+	maskRulesMutex.RUnlock()
+
+	if configFile != "" && !utils.NotExistFile(configFile) {
+		config, err := utils.ParseAndCacheFile(configFile, parseMaskConfiguration)
+		if err != nil {
+			log.Errorf("read mask config [%s] failed, err: %v", configFile, err.Error())
+		} else {
+			rules = append(rules, config.Operations[operationPath]...)
+		}
+	}
+
+	var o O
+	return append(rules, fetchTypeMaskRules(reflect.TypeOf(o))...)
+}
+
+// parseMaskConfiguration 存在非法规则时返回错误，整个文件不生效
+func parseMaskConfiguration(content []byte) (config *MaskConfiguration, err error) {
+	if err = json.Unmarshal(content, &config); err != nil {
+		return
+	}
+	if config == nil {
+		config = &MaskConfiguration{}
+		return
+	}
+	for operationPath, rules := range config.Operations {
+		for _, rule := range rules {
+			if rule == nil {
+				continue
+			}
+			if err = rule.validate(); err != nil {
+				err = fmt.Errorf("invalid mask rule [%s] of [%s]: %w", rule.Path, operationPath, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+func maskOperationResponse(rules []*MaskRule, user *types.User, output []byte) []byte {
+	if len(rules) == 0 {
+		return output
+	}
+
+	dataResult := gjson.GetBytes(output, maskResponseDataPath)
+	if !dataResult.Exists() || dataResult.Type == gjson.Null {
+		return output
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(dataResult.Raw), &data); err != nil {
+		return output
+	}
+	for _, rule := range rules {
+		if rule.exempted(user) {
+			continue
+		}
+		data = rule.apply(data, strings.Split(rule.Path, "."))
+	}
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return output
+	}
+	output, _ = sjson.SetRawBytes(output, maskResponseDataPath, dataBytes)
+	return output
+}
+
+func (r *MaskRule) exempted(user *types.User) bool {
+	if user == nil {
+		return false
+	}
+	for _, role := range r.ExemptRoles {
+		if slices.Contains(user.Roles, role) {
+			return true
+		}
+	}
+	for key, expected := range r.ExemptClaims {
+		actual, ok := user.CustomClaims[key]
+		if !ok {
+			continue
+		}
+		if actualArray, ok := actual.([]any); ok {
+			for _, item := range actualArray {
+				if cast.ToString(item) == cast.ToString(expected) {
+					return true
+				}
+			}
+			continue
+		}
+		if cast.ToString(actual) == cast.ToString(expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MaskRule) apply(data any, path []string) any {
+	if array, ok := data.([]any); ok {
+		if len(path) > 0 && path[0] == maskArrayWildcard {
+			path = path[1:]
+		}
+		for i, item := range array {
+			array[i] = r.apply(item, path)
+		}
+		return array
+	}
+
+	object, ok := data.(map[string]any)
+	if !ok || len(path) == 0 {
+		return data
+	}
+
+	value, ok := object[path[0]]
+	if !ok {
+		return object
+	}
+	if len(path) > 1 {
+		object[path[0]] = r.apply(value, path[1:])
+		return object
+	}
+
+	if r.Action == MaskAction_remove {
+		delete(object, path[0])
+		return object
+	}
+	object[path[0]] = r.transform(value)
+	return object
+}
+
+func (r *MaskRule) transform(value any) any {
+	if value == nil {
+		return nil
+	}
+	if array, ok := value.([]any); ok {
+		for i, item := range array {
+			array[i] = r.transform(item)
+		}
+		return array
+	}
+
+	str := cast.ToString(value)
+	if _, isObject := value.(map[string]any); isObject {
+		objectBytes, _ := json.Marshal(value)
+		str = string(objectBytes)
+	}
+	switch r.Action {
+	case MaskAction_hash:
+		sum := sha256.Sum256([]byte(str))
+		return hex.EncodeToString(sum[:])
+	default:
+		runes := []rune(str)
+		if r.KeepPrefix+r.KeepSuffix >= len(runes) {
+			return strings.Repeat(maskDefaultCharacter, len(runes))
+		}
+		return string(runes[:r.KeepPrefix]) + strings.Repeat(maskDefaultCharacter, len(runes)-r.KeepPrefix-r.KeepSuffix) + string(runes[len(runes)-r.KeepSuffix:])
+	}
+}
+
+// fetchTypeMaskRules 解析响应类型上的 mask 标签，例如 `mask:"mask,exempt=admin|auditor"`
+func fetchTypeMaskRules(t reflect.Type) []*MaskRule {
+	if t == nil {
+		return nil
+	}
+	if value, ok := maskTypeRulesMap.Load(t); ok {
+		return value.([]*MaskRule)
+	}
+
+	var rules []*MaskRule
+	collectTypeMaskRules(t, nil, make(map[reflect.Type]bool), &rules)
+	maskTypeRulesMap.Store(t, rules)
+	return rules
+}
+
+func collectTypeMaskRules(t reflect.Type, path []string, visited map[reflect.Type]bool, rules *[]*MaskRule) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return
+	}
+
+	visited[t] = true
+	defer delete(visited, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			collectTypeMaskRules(field.Type, path, visited, rules)
+			continue
+		}
+
+		name := field.Name
+		if jsonTag := field.Tag.Get("json"); jsonTag != "" {
+			if jsonName, _, _ := strings.Cut(jsonTag, ","); jsonName == "-" {
+				continue
+			} else if jsonName != "" {
+				name = jsonName
+			}
+		}
+		fieldPath := appendItem(path, name)
+		if maskTag, ok := field.Tag.Lookup(maskStructTag); ok {
+			if rule, err := parseMaskTag(maskTag); err != nil {
+				log.Errorf("parse mask tag of [%s.%s] failed, err: %v", t.Name(), field.Name, err.Error())
+			} else {
+				rule.Path = strings.Join(fieldPath, ".")
+				*rules = append(*rules, rule)
+			}
+			continue
+		}
+		collectTypeMaskRules(field.Type, fieldPath, visited, rules)
+	}
+}
+
+func parseMaskTag(tag string) (rule *MaskRule, err error) {
+	items := strings.Split(tag, ",")
+	rule = &MaskRule{Action: MaskAction(strings.TrimSpace(items[0]))}
+	if err = rule.validate(); err != nil {
+		return
+	}
+
+	for _, item := range items[1:] {
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(item, maskTagExemptPrefix) {
+			roles := strings.TrimPrefix(item, maskTagExemptPrefix)
+			rule.ExemptRoles = append(rule.ExemptRoles, strings.Split(roles, "|")...)
+		}
+	}
+	return
+}
+
+func (r *MaskRule) validate() error {
+	switch r.Action {
+	case MaskAction_mask, MaskAction_hash, MaskAction_remove:
+	default:
+		return fmt.Errorf("unsupported mask action [%s]", r.Action)
+	}
+	if r.KeepPrefix < 0 || r.KeepSuffix < 0 {
+		return errors.New("keepPrefix and keepSuffix must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package plugins
+
+import (
+	"custom-go/pkg/types"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMaskRuleApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     *MaskRule
+		data     string
+		expected string
+	}{
+		{
+			name:     "mask keeps prefix and suffix",
+			rule:     &MaskRule{Path: "phone", Action: MaskAction_mask, KeepPrefix: 3, KeepSuffix: 2},
+			data:     `{"phone":"13800001234"}`,
+			expected: `{"phone":"138******34"}`,
+		},
+		{
+			name:     "mask shorter than kept characters",
+			rule:     &MaskRule{Path: "code", Action: MaskAction_mask, KeepPrefix: 2, KeepSuffix: 2},
+			data:     `{"code":"abc"}`,
+			expected: `{"code":"***"}`,
+		},
+		{
+			name:     "mask counts runes",
+			rule:     &MaskRule{Path: "name", Action: MaskAction_mask, KeepPrefix: 1},
+			data:     `{"name":"张三丰"}`,
+			expected: `{"name":"张**"}`,
+		},
+		{
+			name:     "remove field",
+			rule:     &MaskRule{Path: "password", Action: MaskAction_remove},
+			data:     `{"name":"a","password":"secret"}`,
+			expected: `{"name":"a"}`,
+		},
+		{
+			name:     "hash field",
+			rule:     &MaskRule{Path: "email", Action: MaskAction_hash},
+			data:     `{"email":"a@b.c"}`,
+			expected: `{"email":"d648b243a3e817eaa3309e00e183483f2867baadf522099f0c2121770536b25a"}`,
+		},
+		{
+			name:     "arrays are expanded implicitly",
+			rule:     &MaskRule{Path: "users.phone", Action: MaskAction_mask},
+			data:     `{"users":[{"phone":"123"},{"phone":"45"}]}`,
+			expected: `{"users":[{"phone":"***"},{"phone":"**"}]}`,
+		},
+		{
+			name:     "explicit array wildcard",
+			rule:     &MaskRule{Path: "users.#.phone", Action: MaskAction_remove},
+			data:     `{"users":[{"id":1,"phone":"123"}]}`,
+			expected: `{"users":[{"id":1}]}`,
+		},
+		{
+			name:     "nested objects",
+			rule:     &MaskRule{Path: "user.profile.idCard", Action: MaskAction_mask, KeepSuffix: 4},
+			data:     `{"user":{"profile":{"idCard":"110101199001011234"}}}`,
+			expected: `{"user":{"profile":{"idCard":"**************1234"}}}`,
+		},
+		{
+			name:     "array value masks each item",
+			rule:     &MaskRule{Path: "tags", Action: MaskAction_mask},
+			data:     `{"tags":["ab","c"]}`,
+			expected: `{"tags":["**","*"]}`,
+		},
+		{
+			name:     "object value is masked as json",
+			rule:     &MaskRule{Path: "meta", Action: MaskAction_mask},
+			data:     `{"meta":{"a":1}}`,
+			expected: `{"meta":"*******"}`,
+		},
+		{
+			name:     "null and missing fields are kept",
+			rule:     &MaskRule{Path: "a.b", Action: MaskAction_mask},
+			data:     `{"a":null,"c":"x"}`,
+			expected: `{"a":null,"c":"x"}`,
+		},
+		{
+			name:     "non string values are stringified",
+			rule:     &MaskRule{Path: "age", Action: MaskAction_mask},
+			data:     `{"age":42}`,
+			expected: `{"age":"**"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data any
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			data = tt.rule.apply(data, strings.Split(tt.rule.Path, "."))
+			actual, err := json.Marshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != tt.expected {
+				t.Fatalf("expected %s but got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMaskRuleExempted(t *testing.T) {
+	rule := &MaskRule{ExemptRoles: []string{"admin"}, ExemptClaims: map[string]any{"dept": "audit"}}
+	tests := []struct {
+		name     string
+		user     *types.User
+		expected bool
+	}{
+		{name: "anonymous", user: nil, expected: false},
+		{name: "exempt role", user: &types.User{Roles: []string{"user", "admin"}}, expected: true},
+		{name: "exempt claim", user: &types.User{CustomClaims: map[string]any{"dept": "audit"}}, expected: true},
+		{name: "exempt claim in array", user: &types.User{CustomClaims: map[string]any{"dept": []any{"sales", "audit"}}}, expected: true},
+		{name: "other user", user: &types.User{Roles: []string{"user"}, CustomClaims: map[string]any{"dept": "sales"}}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := rule.exempted(tt.user); actual != tt.expected {
+				t.Fatalf("expected [%v] but got [%v]", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseMaskConfiguration(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		errSubstr string
+	}{
+		{name: "valid", content: `{"operations":{"User/Get":[{"path":"phone","action":"mask","keepPrefix":3}]}}`},
+		{name: "empty", content: `null`},
+		{name: "unknown action", content: `{"operations":{"User/Get":[{"path":"phone","action":"blur"}]}}`, errSubstr: "unsupported mask action"},
+		{name: "negative keep", content: `{"operations":{"User/Get":[{"path":"phone","action":"mask","keepSuffix":-1}]}}`, errSubstr: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseMaskConfiguration([]byte(tt.content))
+			if tt.errSubstr == "" {
+				if err != nil || config == nil {
+					t.Fatalf("expected config but got err [%v]", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Fatalf("expected error containing [%s] but got [%v]", tt.errSubstr, err)
+			}
+		})
+	}
+}
+
+func TestParseMaskTag(t *testing.T) {
+	rule, err := parseMaskTag("mask, exempt=admin|auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Action != MaskAction_mask || strings.Join(rule.ExemptRoles, ",") != "admin,auditor" {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	if _, err = parseMaskTag("blur"); err == nil {
+		t.Fatalf("expected error for unknown action")
+	}
+}
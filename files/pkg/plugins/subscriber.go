@@ -0,0 +1,245 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"custom-go/pkg/types"
+	"encoding/json"
+	"errors"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/r3labs/sse/v2"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	headerLastEventId   = "Last-Event-ID"
+	subscribeOptionsKey = "subscribeOptions"
+)
+
+var (
+	headerId    = []byte("id:")
+	headerEvent = []byte("event:")
+	eventDone   = [][]byte{[]byte("done"), []byte("complete")}
+)
+
+// SubscribeOptions 订阅异常断开（非正常结束、非上下文结束）后的重连策略
+type SubscribeOptions struct {
+	// 最大连续重连次数，为 0 时不重连，小于 0 时无限重连，收到数据后重新计数
+	MaxReconnects int
+	// 重连前的退避时间，每次翻倍并加入随机抖动，不超过 MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// 重连时通过 Last-Event-ID 请求头携带最后收到的事件 id
+	ResendLastEventId bool
+}
+
+var DefaultSubscribeOptions = &SubscribeOptions{
+	MaxReconnects: 5,
+	Backoff:       500 * time.Millisecond,
+	MaxBackoff:    10 * time.Second,
+}
+
+func (m *Subscriber[I, O]) Subscribe(input I, client *types.InternalClient) (dataChan chan SubscriberData[O], err error) {
+	return m.SubscribeContext(client.RequestContext(), input, client)
+}
+
+// SubscribeContext 订阅正常结束或上下文结束时关闭 dataChan，异常断开时按 options 重连，
+// 重连失败时推送携带原始错误的 SubscriberData 后关闭 dataChan
+func (m *Subscriber[I, O]) SubscribeContext(ctx context.Context, input I, client *types.InternalClient, options ...*SubscribeOptions) (dataChan chan SubscriberData[O], err error) {
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	if len(options) > 0 && options[0] != nil {
+		ctx = context.WithValue(ctx, subscribeOptionsKey, options[0])
+	}
+	request := &types.OperationRequest{Path: m.Path, Type: types.OperationType_SUBSCRIPTION, Input: input}
+	events, err := fetchOperationExecutor(client).Subscribe(ctx, client, request)
+	if err != nil {
+		return
+	}
+
+	dataChan = make(chan SubscriberData[O])
+	go func() {
+		defer close(dataChan)
+		for event := range events {
+			var item SubscriberData[O]
+			if event.Err != nil {
+				item = newSubscriberError[O](event.Err)
+			} else if unmarshalErr := json.Unmarshal(event.Data, &item.Data); unmarshalErr != nil {
+				item = newSubscriberError[O](unmarshalErr)
+			}
+			if !sendSubscriberData(ctx, dataChan, item) {
+				return
+			}
+		}
+	}()
+	return
+}
+
+// SubscribeFunc 阻塞直至订阅结束，handle 返回错误或订阅出错时取消订阅并返回该错误
+func (m *Subscriber[I, O]) SubscribeFunc(ctx context.Context, input I, client *types.InternalClient, handle func(O) error, options ...*SubscribeOptions) error {
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	subscribeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dataChan, err := m.SubscribeContext(subscribeCtx, input, client, options...)
+	if err != nil {
+		return err
+	}
+
+	for item := range dataChan {
+		if item.Err != nil {
+			return item.Err
+		}
+		if len(item.Errors) > 0 {
+			return errors.New(item.Errors[0].Message)
+		}
+		if err = handle(item.Data); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// subscribeNode 请求节点订阅并解析 sse 事件，options 通过 ctx 传入
+func subscribeNode(ctx context.Context, client *types.InternalClient, request *types.OperationRequest) (events chan *types.SubscriptionEvent, err error) {
+	subscribeOptions := DefaultSubscribeOptions
+	if options, ok := ctx.Value(subscribeOptionsKey).(*SubscribeOptions); ok {
+		subscribeOptions = options
+	}
+	resp, err := connectSubscription(ctx, client, request, "")
+	if err != nil {
+		return
+	}
+
+	events = make(chan *types.SubscriptionEvent)
+	go func() {
+		defer close(events)
+		var (
+			lastEventId string
+			reconnects  int
+		)
+		for {
+			received, streamErr := readSubscriberStream(ctx, resp.Body, events, &lastEventId)
+			_ = resp.Body.Close()
+			if streamErr == nil || ctx.Err() != nil {
+				return
+			}
+			if received {
+				reconnects = 0
+			}
+
+			for {
+				if subscribeOptions.MaxReconnects >= 0 && reconnects >= subscribeOptions.MaxReconnects {
+					sendSubscriptionEvent(ctx, events, &types.SubscriptionEvent{Err: streamErr})
+					return
+				}
+				backoffPolicy := &types.RequestPolicy{Backoff: subscribeOptions.Backoff, MaxBackoff: subscribeOptions.MaxBackoff}
+				if sleepContext(ctx, retryBackoff(backoffPolicy, reconnects)) != nil {
+					return
+				}
+				reconnects++
+
+				var resendEventId string
+				if subscribeOptions.ResendLastEventId {
+					resendEventId = lastEventId
+				}
+				if resp, streamErr = connectSubscription(ctx, client, request, resendEventId); streamErr == nil {
+					break
+				}
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}
+	}()
+	return
+}
+
+func connectSubscription(ctx context.Context, client *types.InternalClient, request *types.OperationRequest, lastEventId string) (*http.Response, error) {
+	if lastEventId != "" {
+		if client == nil {
+			client = defaultInternalClient
+		}
+		resendClient := *client
+		resendClient.ExtraHeaders = types.RequestHeaders{headerLastEventId: lastEventId}
+		for k, v := range client.ExtraHeaders {
+			resendClient.ExtraHeaders[k] = v
+		}
+		client = &resendClient
+	}
+	options := types.OperationArgsWithInput[any]{Input: request.Input, Context: ctx}
+	return internalRequest[any](client, request.Path, types.OperationType_SUBSCRIPTION, options)
+}
+
+// readSubscriberStream 读取 sse 事件直至结束，正常结束（EOF 或 done 事件）时返回 nil
+func readSubscriberStream(ctx context.Context, body io.Reader, events chan *types.SubscriptionEvent, lastEventId *string) (received bool, err error) {
+	reader := sse.NewEventStreamReader(body, math.MaxInt)
+	for {
+		readMsg, readErr := reader.ReadEvent()
+		if readErr != nil {
+			if readErr == io.EOF {
+				return
+			}
+			err = readErr
+			return
+		}
+		if len(readMsg) == 0 {
+			continue
+		}
+
+		// normalize the crlf to lf to make it easier to split the lines.
+		// split the line by "\n" or "\r", per the spec.
+		lines := bytes.FieldsFunc(readMsg, func(r rune) bool { return r == '\n' || r == '\r' })
+		for _, line := range lines {
+			switch {
+			case bytes.HasPrefix(line, headerId):
+				*lastEventId = string(trim(line[len(headerId):]))
+			case bytes.HasPrefix(line, headerEvent):
+				event := trim(line[len(headerEvent):])
+				for _, done := range eventDone {
+					if bytes.Equal(event, done) {
+						return
+					}
+				}
+			case bytes.HasPrefix(line, headerData):
+				lineData := trim(line[len(headerData):])
+				if len(lineData) == 0 {
+					continue
+				}
+				received = true
+				if !sendSubscriptionEvent(ctx, events, &types.SubscriptionEvent{Data: append([]byte(nil), lineData...)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func newSubscriberError[O any](err error) SubscriberData[O] {
+	return SubscriberData[O]{Errors: []gqlerrors.FormattedError{{Message: err.Error()}}, Err: err}
+}
+
+// sendSubscriptionEvent 上下文结束时放弃推送并返回 false
+func sendSubscriptionEvent(ctx context.Context, events chan *types.SubscriptionEvent, event *types.SubscriptionEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendSubscriberData 上下文结束时放弃推送并返回 false
+func sendSubscriberData[O any](ctx context.Context, dataChan chan SubscriberData[O], data SubscriberData[O]) bool {
+	select {
+	case dataChan <- data:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
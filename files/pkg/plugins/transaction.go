@@ -0,0 +1,281 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"custom-go/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTransactionFinished 事务已提交后再次结束时返回，已回滚时返回回滚原因
+	ErrTransactionFinished = errors.New("transaction already finished")
+	// ErrTransactionOptionUnsupported 节点不支持按调用指定 IsolationLevel 和 MaxWaitSeconds，需在 operation 的事务配置中设置
+	ErrTransactionOptionUnsupported = errors.New("transaction option is not supported")
+	// TransactionEndpointPath 结束事务时经由 client 的 OperationExecutor 执行的 path，input 为 *TransactionFinishRequest
+	TransactionEndpointPath = string(types.InternalEndpoint_internalTransaction)
+)
+
+// TransactionFinishRequest Error 为空时提交，否则回滚
+type TransactionFinishRequest struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Transaction 通过 Client 执行的 operation 均加入该事务，Client 为独立副本，不影响原 client
+// 嵌套事务与外层共用同一个节点事务，不支持保存点：嵌套事务的回滚无法只撤销其自身的修改，
+// 而是使外层只能回滚，其 AfterCommit 回调随之丢弃
+type Transaction struct {
+	Id     string
+	Client *types.InternalClient
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	requestCtx  context.Context
+	parent      *Transaction
+	joined      bool
+	mutex       sync.Mutex
+	finished    bool
+	finishErr   error
+	rollbackErr error
+	afterCommit []func()
+}
+
+// BeginTransaction ctx 为 nil 时使用 client 所属钩子请求的上下文，options 的 TimeoutSeconds 大于 0 时超时自动回滚
+// 设置 IsolationLevel 或 MaxWaitSeconds 时返回 ErrTransactionOptionUnsupported；client 已处于 Transaction 中时开启嵌套事务
+// client 携带节点开启的事务（钩子在节点事务中被调用）时以 X-Transaction-Manually 加入该事务，
+// 提交和回滚均不通知节点，回滚仅返回错误，需由钩子返回错误使节点回滚
+func BeginTransaction(ctx context.Context, client *types.InternalClient, options ...*types.OperationTransaction) (*Transaction, error) {
+	if len(options) > 0 && options[0] != nil && (options[0].IsolationLevel != 0 || options[0].MaxWaitSeconds != 0) {
+		return nil, fmt.Errorf("%w: isolationLevel and maxWaitSeconds must be configured on the operation", ErrTransactionOptionUnsupported)
+	}
+	if client == nil {
+		client = defaultInternalClient
+	}
+	if ctx == nil {
+		ctx = client.RequestContext()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	transactionId := client.ExtraHeaders.Get(string(types.TransactionHeader_X_Transaction_Id))
+	if value, ok := activeTransactions.Load(transactionId); ok && transactionId != "" {
+		return value.(*Transaction).Begin(), nil
+	}
+	if transactionId == "" && nodeCircuitBreaker.opened() {
+		return nil, ErrCircuitOpen
+	}
+
+	tx := &Transaction{Id: transactionId, requestCtx: ctx, joined: transactionId != ""}
+	if len(options) > 0 && options[0] != nil && options[0].TimeoutSeconds > 0 {
+		tx.ctx, tx.cancel = context.WithTimeout(ctx, time.Duration(options[0].TimeoutSeconds)*time.Second)
+	} else {
+		tx.ctx, tx.cancel = context.WithCancel(ctx)
+	}
+	if tx.joined {
+		tx.Client = scopeTransactionClient(tx.ctx, client, types.RequestHeaders{string(types.TransactionHeader_X_Transaction_Manually): "true"})
+	} else {
+		tx.Id = uuid.New().String()
+		tx.Client = scopeTransactionClient(tx.ctx, client, types.RequestHeaders{string(types.TransactionHeader_X_Transaction_Id): tx.Id})
+		activeTransactions.Store(tx.Id, tx)
+	}
+	go func() {
+		<-tx.ctx.Done()
+		_ = tx.finish(tx.ctx.Err())
+	}()
+	return tx, nil
+}
+
+var activeTransactions = &sync.Map{}
+
+func scopeTransactionClient(ctx context.Context, client *types.InternalClient, headers types.RequestHeaders) *types.InternalClient {
+	scoped := *client
+	scoped.ExtraHeaders = make(types.RequestHeaders, len(client.ExtraHeaders)+len(headers))
+	for k, v := range client.ExtraHeaders {
+		scoped.ExtraHeaders[k] = v
+	}
+	for k, v := range headers {
+		scoped.ExtraHeaders[k] = v
+	}
+	scoped.Context = ctx
+	return &scoped
+}
+
+// WithTransaction execute 返回错误、panic 或上下文结束时回滚，否则提交；panic 在回滚后继续抛出
+func WithTransaction(ctx context.Context, client *types.InternalClient, execute func(*Transaction) error, options ...*types.OperationTransaction) (err error) {
+	tx, err := BeginTransaction(ctx, client, options...)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+	if err = execute(tx); err != nil {
+		_ = tx.Rollback(err)
+		return
+	}
+	return tx.Commit()
+}
+
+// Begin 开启嵌套事务，与外层共用同一个节点事务，不创建保存点
+func (t *Transaction) Begin() *Transaction {
+	child := &Transaction{Id: t.Id, parent: t}
+	child.ctx, child.cancel = context.WithCancel(t.ctx)
+	child.Client = scopeTransactionClient(child.ctx, t.Client, nil)
+	return child
+}
+
+func (t *Transaction) Context() context.Context {
+	return t.ctx
+}
+
+// AfterCommit 注册最外层事务提交成功后执行的回调，加入节点事务时在本地提交后执行，不等待节点事务的结果
+func (t *Transaction) AfterCommit(callback func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.afterCommit = append(t.afterCommit, callback)
+}
+
+// Commit 最外层事务中存在已回滚的嵌套事务时改为回滚并返回其错误
+func (t *Transaction) Commit() error {
+	return t.finish(nil)
+}
+
+func (t *Transaction) Rollback(reason error) error {
+	if reason == nil {
+		reason = errors.New("transaction rollback")
+	}
+	if err := t.finish(reason); err != nil && !errors.Is(err, reason) {
+		return err
+	}
+	return nil
+}
+
+func (t *Transaction) finish(reason error) (err error) {
+	t.mutex.Lock()
+	if t.finished {
+		t.mutex.Unlock()
+		if t.finishErr != nil {
+			return t.finishErr
+		}
+		return ErrTransactionFinished
+	}
+	t.finished = true
+	if reason == nil && t.rollbackErr != nil {
+		reason = t.rollbackErr
+	}
+	if reason == nil && t.parent == nil {
+		reason = t.ctx.Err()
+	}
+	t.finishErr = reason
+	callbacks := t.afterCommit
+	t.mutex.Unlock()
+	defer t.cancel()
+
+	if t.parent != nil {
+		t.parent.mutex.Lock()
+		defer t.parent.mutex.Unlock()
+		if reason != nil {
+			if t.parent.rollbackErr == nil {
+				t.parent.rollbackErr = reason
+			}
+			return reason
+		}
+		t.parent.afterCommit = append(t.parent.afterCommit, callbacks...)
+		return nil
+	}
+
+	if !t.joined {
+		activeTransactions.Delete(t.Id)
+		if err = notifyTransactionFinish(detachedContext{parent: t.requestCtx}, t.Client, reason); err != nil {
+			return
+		}
+	}
+	if reason != nil {
+		return reason
+	}
+	for _, callback := range callbacks {
+		callback()
+	}
+	return
+}
+
+// notifyTransactionFinish 经由 client 的 OperationExecutor 通知节点结束事务，reason 非空时回滚
+func notifyTransactionFinish(ctx context.Context, client *types.InternalClient, reason error) (err error) {
+	request := &TransactionFinishRequest{}
+	if reason != nil {
+		request.Error = reason.Error()
+	}
+	executeRequest := &types.OperationRequest{Path: TransactionEndpointPath, Type: types.OperationType_MUTATION, Input: request}
+	_, err = fetchOperationExecutor(client).Execute(ctx, client, executeRequest)
+	return
+}
+
+// requestTransactionFinish 结束事务的通知不受熔断限制，避免事务悬挂
+func requestTransactionFinish(ctx context.Context, client *types.InternalClient, request *TransactionFinishRequest) (respBytes []byte, err error) {
+	var body []byte
+	if request.Error != "" {
+		if body, err = json.Marshal(request); err != nil {
+			return
+		}
+	}
+	if policy := fetchRequestPolicy(client, TransactionEndpointPath); policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, types.PrivateNodeUrl+TransactionEndpointPath, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	for k, v := range client.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+	resp, err := internalHttpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if respBytes, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New(string(respBytes))
+		respBytes = nil
+	}
+	return
+}
+
+// detachedContext 保留 parent 的值但不随其结束，钩子请求结束或事务超时后仍能通知节点回滚
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
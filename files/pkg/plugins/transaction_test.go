@@ -0,0 +1,199 @@
+package plugins
+
+import (
+	"context"
+	"custom-go/pkg/types"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type testFinishRecorder struct {
+	finishes []*TransactionFinishRequest
+	mutex    sync.Mutex
+}
+
+func (r *testFinishRecorder) RecordExecute(path string, input any, _ []byte, _ error) {
+	if request, ok := input.(*TransactionFinishRequest); ok && path == TransactionEndpointPath {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.finishes = append(r.finishes, request)
+	}
+}
+
+func (r *testFinishRecorder) RecordSubscribe(string, any) func([]byte) {
+	return func([]byte) {}
+}
+
+func newTestTransactionClient(headers types.RequestHeaders) (*types.InternalClient, *testFinishRecorder) {
+	recorder := &testFinishRecorder{}
+	client := types.NewEmptyInternalClient()
+	client.ExtraHeaders = headers
+	client.Executor = NewRecordingOperationExecutor(NewMemoryOperationExecutor(), recorder)
+	return client, recorder
+}
+
+func TestTransactionFinish(t *testing.T) {
+	errNested := errors.New("nested failed")
+	tests := []struct {
+		name string
+		// run 在外层事务中执行，返回值作为外层提交或回滚的依据
+		run            func(tx *Transaction, called *[]string) error
+		expectErr      error
+		expectFinishes []string
+		expectCalled   []string
+	}{
+		{
+			name: "commit runs callbacks",
+			run: func(tx *Transaction, called *[]string) error {
+				tx.AfterCommit(func() { *called = append(*called, "outer") })
+				return nil
+			},
+			expectFinishes: []string{""},
+			expectCalled:   []string{"outer"},
+		},
+		{
+			name: "rollback drops callbacks",
+			run: func(tx *Transaction, called *[]string) error {
+				tx.AfterCommit(func() { *called = append(*called, "outer") })
+				return errors.New("outer failed")
+			},
+			expectErr:      errors.New("outer failed"),
+			expectFinishes: []string{"outer failed"},
+		},
+		{
+			name: "nested commit defers callbacks to outer commit",
+			run: func(tx *Transaction, called *[]string) error {
+				nested := tx.Begin()
+				nested.AfterCommit(func() { *called = append(*called, "nested") })
+				if err := nested.Commit(); err != nil {
+					return err
+				}
+				if len(*called) > 0 {
+					t.Fatalf("nested callbacks run before outer commit")
+				}
+				tx.AfterCommit(func() { *called = append(*called, "outer") })
+				return nil
+			},
+			expectFinishes: []string{""},
+			expectCalled:   []string{"nested", "outer"},
+		},
+		{
+			name: "nested rollback forces outer rollback",
+			run: func(tx *Transaction, called *[]string) error {
+				tx.AfterCommit(func() { *called = append(*called, "outer") })
+				nested := tx.Begin()
+				nested.AfterCommit(func() { *called = append(*called, "nested") })
+				if err := nested.Rollback(errNested); err != nil {
+					t.Fatalf("nested rollback: %v", err)
+				}
+				// 外层忽略嵌套事务的错误继续提交
+				return nil
+			},
+			expectErr:      errNested,
+			expectFinishes: []string{"nested failed"},
+		},
+		{
+			name: "nested transaction shares the outer id",
+			run: func(tx *Transaction, _ *[]string) error {
+				nested := tx.Begin()
+				if nested.Id != tx.Id || nested.Client.ExtraHeaders.Get(string(types.TransactionHeader_X_Transaction_Id)) != tx.Id {
+					t.Fatalf("nested transaction does not share outer id")
+				}
+				return nested.Commit()
+			},
+			expectFinishes: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, recorder := newTestTransactionClient(nil)
+			var called []string
+			err := WithTransaction(context.Background(), client, func(tx *Transaction) error {
+				return tt.run(tx, &called)
+			})
+			if tt.expectErr == nil && err != nil || tt.expectErr != nil && (err == nil || err.Error() != tt.expectErr.Error()) {
+				t.Fatalf("expected err [%v] but got [%v]", tt.expectErr, err)
+			}
+			var finishes []string
+			for _, finish := range recorder.finishes {
+				finishes = append(finishes, finish.Error)
+			}
+			assertStrings(t, "finishes", finishes, tt.expectFinishes)
+			assertStrings(t, "callbacks", called, tt.expectCalled)
+		})
+	}
+}
+
+func TestTransactionFinishedTwice(t *testing.T) {
+	client, recorder := newTestTransactionClient(nil)
+	tx, err := BeginTransaction(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); !errors.Is(err, ErrTransactionFinished) {
+		t.Fatalf("expected ErrTransactionFinished but got [%v]", err)
+	}
+	if err = tx.Rollback(errors.New("late")); !errors.Is(err, ErrTransactionFinished) {
+		t.Fatalf("expected ErrTransactionFinished but got [%v]", err)
+	}
+	if len(recorder.finishes) != 1 {
+		t.Fatalf("expected node notified once but got %d", len(recorder.finishes))
+	}
+}
+
+func TestTransactionJoinsNodeTransaction(t *testing.T) {
+	client, recorder := newTestTransactionClient(types.RequestHeaders{string(types.TransactionHeader_X_Transaction_Id): "node-tx"})
+	errFailed := errors.New("failed")
+	err := WithTransaction(context.Background(), client, func(tx *Transaction) error {
+		if tx.Id != "node-tx" || tx.Client.ExtraHeaders.Get(string(types.TransactionHeader_X_Transaction_Manually)) != "true" {
+			t.Fatalf("expected to join node transaction but got headers %v", tx.Client.ExtraHeaders)
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected rollback error returned but got [%v]", err)
+	}
+	if len(recorder.finishes) != 0 {
+		t.Fatalf("expected node transaction left to the node but got %d notification(s)", len(recorder.finishes))
+	}
+}
+
+func TestTransactionRollbackOnPanic(t *testing.T) {
+	client, recorder := newTestTransactionClient(nil)
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("expected panic re-thrown but got [%v]", r)
+		}
+		if len(recorder.finishes) != 1 || recorder.finishes[0].Error != "panic: boom" {
+			t.Fatalf("expected rollback with panic reason but got %v", recorder.finishes)
+		}
+	}()
+	_ = WithTransaction(context.Background(), client, func(*Transaction) error {
+		panic("boom")
+	})
+}
+
+func TestBeginTransactionRejectsOptions(t *testing.T) {
+	client, _ := newTestTransactionClient(nil)
+	for _, option := range []*types.OperationTransaction{{IsolationLevel: 1}, {MaxWaitSeconds: 1}} {
+		if _, err := BeginTransaction(context.Background(), client, option); !errors.Is(err, ErrTransactionOptionUnsupported) {
+			t.Fatalf("expected ErrTransactionOptionUnsupported but got [%v]", err)
+		}
+	}
+}
+
+func assertStrings(t *testing.T, name string, actual, expected []string) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("%s: expected %v but got %v", name, expected, actual)
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("%s: expected %v but got %v", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package plugins
+
+import (
+	"bytes"
+	"custom-go/pkg/types"
+	"custom-go/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cast"
+	"golang.org/x/exp/slices"
+	"sort"
+	"strings"
+)
+
+type TransformKind string
+
+const (
+	// TransformKind_get 取 From 处的值写入 To，与节点的 @transform(get) 一致
+	TransformKind_get TransformKind = "get"
+	// TransformKind_rename 将 From 处的字段重命名为 To 的最后一段
+	TransformKind_rename TransformKind = "rename"
+	// TransformKind_flatten 数组的数组展开一层，对象则将其字段提升到上一层
+	TransformKind_flatten TransformKind = "flatten"
+	// TransformKind_pick 仅保留 From 处对象（或对象数组）的 Fields 字段
+	TransformKind_pick TransformKind = "pick"
+	// TransformKind_dateFormat 按 DateTimeFormat（Go 时间格式）格式化 From 处的时间
+	TransformKind_dateFormat TransformKind = "dateFormat"
+)
+
+const transformArrayItem = "[]"
+
+// Transformation 路径相对于完整的响应（以 data 开头），路径段 [] 表示数组的每个元素
+type Transformation struct {
+	Kind           TransformKind `json:"kind"`
+	From           []string      `json:"from"`
+	To             []string      `json:"to,omitempty"`
+	Fields         []string      `json:"fields,omitempty"`
+	Depth          int64         `json:"depth,omitempty"`
+	DateTimeFormat string        `json:"dateTimeFormat,omitempty"`
+}
+
+// TransformPath 将 data.users.[].name 形式的路径拆分为路径段
+func TransformPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// NewTransformations 转换节点配置中的 PostResolveTransformation
+func NewTransformations(items []*types.PostResolveTransformation) (transformations []*Transformation) {
+	for _, item := range items {
+		if item == nil || item.Get == nil || item.Kind != types.PostResolveTransformationKind_GET_POST_RESOLVE_TRANSFORMATION {
+			continue
+		}
+		transformations = append(transformations, &Transformation{
+			Kind:           TransformKind_get,
+			From:           item.Get.From,
+			To:             item.Get.To,
+			Depth:          item.Depth,
+			DateTimeFormat: item.Get.DateTimeFormat,
+		})
+	}
+	return
+}
+
+// TransformJson 按 Depth 从深到浅依次执行转换
+func TransformJson(data []byte, transformations ...*Transformation) ([]byte, error) {
+	if len(transformations) == 0 {
+		return data, nil
+	}
+
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	sorted := append([]*Transformation(nil), transformations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Depth > sorted[j].Depth
+	})
+	for _, item := range sorted {
+		var err error
+		if document, err = item.apply(document, item.From, item.To); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := utils.MarshalWithoutEscapeHTML(document)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(result), nil
+}
+
+// TransformResponse 转换 operation 的响应并反序列化为新的类型，errors 原样保留
+func TransformResponse[I, O any](resp *types.OperationBodyResponse[I], transformations ...*Transformation) (result *types.OperationBodyResponse[O], err error) {
+	if resp == nil {
+		return
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	if respBytes, err = TransformJson(respBytes, transformations...); err != nil {
+		return
+	}
+	result = &types.OperationBodyResponse[O]{}
+	err = json.Unmarshal(respBytes, result)
+	return
+}
+
+// apply 沿 From 和 To 的公共前缀下探（遇 [] 则逐个元素处理），在分叉处执行转换
+func (t *Transformation) apply(node any, from, to []string) (any, error) {
+	if t.Kind != TransformKind_get {
+		return t.applyAt(node, from)
+	}
+	if len(from) > 0 && len(to) > 0 && from[0] == to[0] {
+		return t.descend(node, from[0], func(child any) (any, error) {
+			return t.apply(child, from[1:], to[1:])
+		})
+	}
+
+	value, err := t.get(node, from)
+	if err != nil {
+		return nil, err
+	}
+	return setTransformValue(node, to, value)
+}
+
+func (t *Transformation) applyAt(node any, path []string) (any, error) {
+	if len(path) == 0 {
+		return t.transform(node)
+	}
+	if t.Kind == TransformKind_rename || t.Kind == TransformKind_flatten {
+		if _, isObject := node.(map[string]any); isObject && len(path) == 1 {
+			return t.transformField(node.(map[string]any), path[0])
+		}
+	}
+	return t.descend(node, path[0], func(child any) (any, error) {
+		return t.applyAt(child, path[1:])
+	})
+}
+
+func (t *Transformation) descend(node any, segment string, next func(any) (any, error)) (any, error) {
+	if segment == transformArrayItem {
+		array, ok := node.([]any)
+		if !ok {
+			return node, nil
+		}
+		for i, item := range array {
+			value, err := next(item)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = value
+		}
+		return array, nil
+	}
+
+	object, ok := node.(map[string]any)
+	if !ok {
+		return node, nil
+	}
+	child, ok := object[segment]
+	if !ok {
+		return object, nil
+	}
+	value, err := next(child)
+	if err != nil {
+		return nil, err
+	}
+	object[segment] = value
+	return object, nil
+}
+
+func (t *Transformation) get(node any, path []string) (any, error) {
+	if len(path) == 0 {
+		if t.DateTimeFormat != "" {
+			return formatTransformTime(node, t.DateTimeFormat)
+		}
+		return node, nil
+	}
+	if node == nil {
+		return nil, nil
+	}
+
+	if path[0] == transformArrayItem {
+		array, ok := node.([]any)
+		if !ok {
+			return nil, nil
+		}
+		values := make([]any, 0, len(array))
+		for _, item := range array {
+			value, err := t.get(item, path[1:])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	object, ok := node.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	return t.get(object[path[0]], path[1:])
+}
+
+func setTransformValue(node any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	if path[0] == transformArrayItem {
+		return nil, fmt.Errorf("transformation target path can not contain [%s] after it diverges from source path", transformArrayItem)
+	}
+
+	object, ok := node.(map[string]any)
+	if !ok {
+		object = make(map[string]any)
+	}
+	child, err := setTransformValue(object[path[0]], path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	object[path[0]] = child
+	return object, nil
+}
+
+// transformField 处理需要修改父对象的转换
+func (t *Transformation) transformField(object map[string]any, key string) (any, error) {
+	value, ok := object[key]
+	if !ok {
+		return object, nil
+	}
+
+	switch t.Kind {
+	case TransformKind_rename:
+		if len(t.To) == 0 {
+			return nil, fmt.Errorf("rename transformation of [%s] requires target name", strings.Join(t.From, "."))
+		}
+		delete(object, key)
+		object[t.To[len(t.To)-1]] = value
+	case TransformKind_flatten:
+		if fields, isObject := value.(map[string]any); isObject {
+			delete(object, key)
+			for k, v := range fields {
+				object[k] = v
+			}
+			return object, nil
+		}
+		flattened, err := t.transform(value)
+		if err != nil {
+			return nil, err
+		}
+		object[key] = flattened
+	}
+	return object, nil
+}
+
+func (t *Transformation) transform(value any) (any, error) {
+	switch t.Kind {
+	case TransformKind_flatten:
+		array, ok := value.([]any)
+		if !ok {
+			return value, nil
+		}
+		flattened := make([]any, 0, len(array))
+		for _, item := range array {
+			if itemArray, ok := item.([]any); ok {
+				flattened = append(flattened, itemArray...)
+			} else {
+				flattened = append(flattened, item)
+			}
+		}
+		return flattened, nil
+	case TransformKind_pick:
+		if array, ok := value.([]any); ok {
+			for i, item := range array {
+				picked, err := t.transform(item)
+				if err != nil {
+					return nil, err
+				}
+				array[i] = picked
+			}
+			return array, nil
+		}
+		object, ok := value.(map[string]any)
+		if !ok {
+			return value, nil
+		}
+		for key := range object {
+			if !slices.Contains(t.Fields, key) {
+				delete(object, key)
+			}
+		}
+		return object, nil
+	case TransformKind_dateFormat:
+		return formatTransformTime(value, t.DateTimeFormat)
+	case TransformKind_rename:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unsupported transformation kind [%s]", t.Kind)
+	}
+}
+
+func formatTransformTime(value any, layout string) (any, error) {
+	switch typed := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for i, item := range typed {
+			formatted, err := formatTransformTime(item, layout)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = formatted
+		}
+		return typed, nil
+	case json.Number:
+		if number, err := typed.Int64(); err == nil {
+			value = number
+		}
+	}
+
+	timeValue, err := cast.ToTimeE(value)
+	if err != nil {
+		return nil, err
+	}
+	return timeValue.Format(layout), nil
+}
@@ -0,0 +1,192 @@
+package plugins
+
+import (
+	"custom-go/pkg/types"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type (
+	// TransportRule 各条件之间为且的关系，同一条件的多个值之间为或的关系，为空时匹配任意值
+	TransportRule struct {
+		// operation 名称，支持 path.Match 通配符，如 user/*
+		OperationNames []string
+		// query、mutation 或 subscription
+		OperationTypes []string
+		// 上游请求的主机名（不含端口），支持 path.Match 通配符，如 *.amazonaws.com
+		Hosts []string
+		// 匹配上游请求的完整 URL
+		UrlPattern *regexp.Regexp
+	}
+	TransportRequestHandler  func(*types.HttpTransportHookRequest, *HttpTransportBody) (*types.WunderGraphRequest, error)
+	TransportResponseHandler func(*types.HttpTransportHookRequest, *HttpTransportBody) (*types.WunderGraphResponse, error)
+	// HttpTransportRouter 按规则分发全局 http 钩子，同一阶段的多个处理函数按注册顺序依次执行，
+	// 后一个处理函数看到的是前一个修改后的请求或响应，均未匹配或均未修改时原样放行
+	HttpTransportRouter struct {
+		beforeOriginRequest []*transportRoute[TransportRequestHandler]
+		onOriginRequest     []*transportRoute[TransportRequestHandler]
+		afterOriginResponse []*transportRoute[TransportResponseHandler]
+		onOriginResponse    []*transportRoute[TransportResponseHandler]
+		mutex               sync.RWMutex
+	}
+	transportRoute[H any] struct {
+		rule    *TransportRule
+		handler H
+	}
+)
+
+func NewHttpTransportRouter() *HttpTransportRouter {
+	return &HttpTransportRouter{}
+}
+
+// BeforeOriginRequest rule 为 nil 时匹配所有请求
+func (r *HttpTransportRouter) BeforeOriginRequest(rule *TransportRule, handler TransportRequestHandler) *HttpTransportRouter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.beforeOriginRequest = append(r.beforeOriginRequest, &transportRoute[TransportRequestHandler]{rule: rule, handler: handler})
+	return r
+}
+
+func (r *HttpTransportRouter) OnOriginRequest(rule *TransportRule, handler TransportRequestHandler) *HttpTransportRouter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onOriginRequest = append(r.onOriginRequest, &transportRoute[TransportRequestHandler]{rule: rule, handler: handler})
+	return r
+}
+
+func (r *HttpTransportRouter) AfterOriginResponse(rule *TransportRule, handler TransportResponseHandler) *HttpTransportRouter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.afterOriginResponse = append(r.afterOriginResponse, &transportRoute[TransportResponseHandler]{rule: rule, handler: handler})
+	return r
+}
+
+func (r *HttpTransportRouter) OnOriginResponse(rule *TransportRule, handler TransportResponseHandler) *HttpTransportRouter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onOriginResponse = append(r.onOriginResponse, &transportRoute[TransportResponseHandler]{rule: rule, handler: handler})
+	return r
+}
+
+// Hooks 用于 GlobalConfiguration.HttpTransport，仅包含已注册处理函数的阶段，需在注册全局钩子前完成路由注册
+func (r *HttpTransportRouter) Hooks() (hooks HttpTransportHooks) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.beforeOriginRequest) > 0 {
+		hooks.BeforeOriginRequest = func(hook *types.HttpTransportHookRequest, body *HttpTransportBody) (*types.WunderGraphRequest, error) {
+			return routeTransportRequest(snapshotTransportRoutes(r, &r.beforeOriginRequest), hook, body)
+		}
+	}
+	if len(r.onOriginRequest) > 0 {
+		hooks.OnOriginRequest = func(hook *types.HttpTransportHookRequest, body *HttpTransportBody) (*types.WunderGraphRequest, error) {
+			return routeTransportRequest(snapshotTransportRoutes(r, &r.onOriginRequest), hook, body)
+		}
+	}
+	if len(r.afterOriginResponse) > 0 {
+		hooks.AfterOriginResponse = func(hook *types.HttpTransportHookRequest, body *HttpTransportBody) (*types.WunderGraphResponse, error) {
+			return routeTransportResponse(snapshotTransportRoutes(r, &r.afterOriginResponse), hook, body)
+		}
+	}
+	if len(r.onOriginResponse) > 0 {
+		hooks.OnOriginResponse = func(hook *types.HttpTransportHookRequest, body *HttpTransportBody) (*types.WunderGraphResponse, error) {
+			return routeTransportResponse(snapshotTransportRoutes(r, &r.onOriginResponse), hook, body)
+		}
+	}
+	return
+}
+
+func snapshotTransportRoutes[H any](r *HttpTransportRouter, routes *[]*transportRoute[H]) []*transportRoute[H] {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return *routes
+}
+
+func routeTransportRequest(routes []*transportRoute[TransportRequestHandler], hook *types.HttpTransportHookRequest, body *HttpTransportBody) (result *types.WunderGraphRequest, err error) {
+	for _, route := range routes {
+		if !route.rule.match(body) {
+			continue
+		}
+		newReq, handleErr := route.handler(hook, body)
+		if handleErr != nil {
+			return nil, handleErr
+		}
+		if newReq != nil {
+			body.Request, result = newReq, newReq
+		}
+	}
+	return
+}
+
+func routeTransportResponse(routes []*transportRoute[TransportResponseHandler], hook *types.HttpTransportHookRequest, body *HttpTransportBody) (result *types.WunderGraphResponse, err error) {
+	for _, route := range routes {
+		if !route.rule.match(body) {
+			continue
+		}
+		newResp, handleErr := route.handler(hook, body)
+		if handleErr != nil {
+			return nil, handleErr
+		}
+		if newResp != nil {
+			body.Response, result = newResp, newResp
+		}
+	}
+	return
+}
+
+func (t *TransportRule) match(body *HttpTransportBody) bool {
+	if t == nil {
+		return true
+	}
+	if len(t.OperationNames) > 0 && !matchTransportPattern(t.OperationNames, body.Name) {
+		return false
+	}
+	if len(t.OperationTypes) > 0 && !containsFold(t.OperationTypes, body.Type) {
+		return false
+	}
+	if len(t.Hosts) == 0 && t.UrlPattern == nil {
+		return true
+	}
+
+	requestUrl := transportRequestUrl(body)
+	if t.UrlPattern != nil && !t.UrlPattern.MatchString(requestUrl) {
+		return false
+	}
+	if len(t.Hosts) > 0 {
+		parsedUrl, err := url.Parse(requestUrl)
+		if err != nil || !matchTransportPattern(t.Hosts, strings.ToLower(parsedUrl.Hostname())) {
+			return false
+		}
+	}
+	return true
+}
+
+func transportRequestUrl(body *HttpTransportBody) string {
+	if body.Request != nil && body.Request.RequestURI != "" {
+		return body.Request.RequestURI
+	}
+	if body.Response != nil {
+		return body.Response.RequestURI
+	}
+	return ""
+}
+
+func matchTransportPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(items []string, value string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,21 @@
 package plugins
 
 import (
-	"bytes"
+	"context"
 	"custom-go/pkg/types"
 	"custom-go/pkg/utils"
 	"encoding/json"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/exp/maps"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -24,6 +30,8 @@ type (
 		Profile        UploadProfile
 		Metadata       UploadMetadata
 		Files          []*types.UploadFile
+		// Client 可选，非空时经由其 Executor 上传（与内部调用一致，可被录制、回放和 hooktest 拦截），钩子中传入钩子请求的 InternalClient
+		Client *types.InternalClient
 	}
 	UploadClient types.S3UploadConfiguration
 )
@@ -43,71 +51,233 @@ func NewUploadClient(Name string) *UploadClient {
 	return client
 }
 
-func buildBodyWithFileFormData(data fileFormData, optional ...func(*multipart.Writer)) (body *bytes.Buffer, contentType string, err error) {
-	body = new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-	for field, files := range data {
-		for _, item := range files {
-			var formFile io.Writer
-			if formFile, err = writer.CreateFormFile(field, item.Name); err != nil {
-				return
-			}
+type (
+	multipartField struct {
+		name  string
+		value string
+	}
+	countingWriter struct {
+		count int64
+	}
+	progressReader struct {
+		ctx     context.Context
+		reader  io.Reader
+		file    *types.UploadFile
+		total   int64
+		written int64
+	}
+)
 
-			if _, err = io.Copy(formFile, item.Reader); err != nil {
+const defaultUploadContentType = "application/octet-stream"
+
+var multipartQuoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// streamFileFormData 通过 io.Pipe 边读文件边写出 multipart 请求体，不在内存中缓存文件内容
+// 所有文件大小已知时返回 contentLength，否则为 -1；请求体只能读取一次
+func streamFileFormData(ctx context.Context, data fileFormData, fields ...multipartField) (body io.ReadCloser, contentType string, contentLength int64, err error) {
+	fieldNames := maps.Keys(data)
+	sort.Strings(fieldNames)
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	// 先以空内容写出一遍以计算长度
+	counter := &countingWriter{}
+	lengthWriter := multipart.NewWriter(counter)
+	if err = lengthWriter.SetBoundary(boundary); err != nil {
+		return
+	}
+	contentLength = 0
+	for _, field := range fieldNames {
+		for _, item := range data[field] {
+			if _, err = lengthWriter.CreatePart(uploadFilePartHeader(field, item)); err != nil {
 				return
 			}
+			if size := uploadFileSize(item); size >= 0 && contentLength >= 0 {
+				contentLength += size
+			} else {
+				contentLength = -1
+			}
 		}
 	}
-	for _, v := range optional {
-		v(writer)
+	for _, field := range fields {
+		_ = lengthWriter.WriteField(field.name, field.value)
+	}
+	_ = lengthWriter.Close()
+	if contentLength >= 0 {
+		contentLength += counter.count
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		multipartWriter := multipart.NewWriter(writer)
+		writeErr := multipartWriter.SetBoundary(boundary)
+		for _, field := range fieldNames {
+			for _, item := range data[field] {
+				if writeErr != nil {
+					break
+				}
+				writeErr = writeUploadFilePart(ctx, multipartWriter, field, item)
+			}
+		}
+		for _, field := range fields {
+			if writeErr != nil {
+				break
+			}
+			writeErr = multipartWriter.WriteField(field.name, field.value)
+		}
+		if writeErr == nil {
+			writeErr = multipartWriter.Close()
+		}
+		_ = writer.CloseWithError(writeErr)
+	}()
+
+	body, contentType = reader, mime.FormatMediaType("multipart/form-data", map[string]string{"boundary": boundary})
+	return
+}
+
+func writeUploadFilePart(ctx context.Context, writer *multipart.Writer, field string, item *types.UploadFile) (err error) {
+	part, err := writer.CreatePart(uploadFilePartHeader(field, item))
+	if err != nil {
+		return
 	}
-	if err = writer.Close(); err != nil {
+
+	size := uploadFileSize(item)
+	reader := &progressReader{ctx: ctx, reader: item.Reader, file: item, total: size}
+	if size < 0 {
+		_, err = io.Copy(part, reader)
 		return
 	}
+	if _, err = io.CopyN(part, reader, size); err == io.EOF {
+		err = fmt.Errorf("file [%s] is shorter than its size %d", item.Name, size)
+	}
+	return
+}
+
+func uploadFilePartHeader(field string, item *types.UploadFile) textproto.MIMEHeader {
+	contentType := item.ContentType
+	if contentType == "" {
+		if contentType = mime.TypeByExtension(filepath.Ext(item.Name)); contentType == "" {
+			contentType = defaultUploadContentType
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		multipartQuoteEscaper.Replace(field), multipartQuoteEscaper.Replace(item.Name)))
+	header.Set(echo.HeaderContentType, contentType)
+	return header
+}
 
-	contentType = writer.FormDataContentType()
+// uploadFileSize 未知时返回 -1
+func uploadFileSize(item *types.UploadFile) int64 {
+	if item.Size > 0 {
+		return item.Size
+	}
+	switch reader := item.Reader.(type) {
+	case interface{ Len() int }:
+		return int64(reader.Len())
+	case *os.File:
+		info, err := reader.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.count += int64(len(p))
+	return len(p), nil
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	n, err = r.reader.Read(p)
+	if n > 0 && r.file.Progress != nil {
+		r.written += int64(n)
+		r.file.Progress(r.written, r.total)
+	}
 	return
 }
 
-var uploadHttpClient = http.Client{Timeout: 30 * time.Second}
+// uploadHttpClient 请求体写完后 30 秒内未收到响应时超时，大文件上传不受总时长限制
+var uploadHttpClient = func() *http.Client {
+	transport := internalHttpClient.Transport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &http.Client{Transport: transport}
+}()
+
+// Upload parameter.Client 非空时绑定其所属钩子请求的上下文，否则使用默认客户端直接请求节点
+func (u *UploadClient) Upload(parameter *UploadParameter) (types.UploadedFiles, error) {
+	ctx := context.Background()
+	if parameter.Client != nil {
+		ctx = parameter.Client.RequestContext()
+	}
+	return u.UploadContext(ctx, parameter)
+}
+
+// UploadContext 上下文结束时中止上传
+func (u *UploadClient) UploadContext(ctx context.Context, parameter *UploadParameter) (types.UploadedFiles, error) {
+	client := parameter.Client
+	if client == nil {
+		client = defaultInternalClient
+	}
+	request := &types.UploadRequest{
+		Provider:       u.Name,
+		Profile:        string(parameter.Profile),
+		Directory:      parameter.Directory,
+		KeepOriginName: parameter.KeepOriginName,
+		Metadata:       parameter.Metadata,
+		Headers:        parameter.Headers,
+		Files:          parameter.Files,
+	}
+	return fetchOperationExecutor(client).Upload(ctx, client, request)
+}
 
-func (u *UploadClient) Upload(parameter *UploadParameter) (uploadResp types.UploadedFiles, err error) {
-	body, contentType, err := buildBodyWithFileFormData(fileFormData{"file": parameter.Files})
+func uploadNode(ctx context.Context, request *types.UploadRequest) (uploadResp types.UploadedFiles, err error) {
+	body, contentType, contentLength, err := streamFileFormData(ctx, fileFormData{"file": request.Files})
 	if err != nil {
 		return
 	}
+	defer func() { _ = body.Close() }()
 
-	uploadPath := types.PrivateNodeUrl + strings.ReplaceAll(string(types.InternalEndpoint_s3upload), "{provider}", u.Name)
+	uploadPath := types.PrivateNodeUrl + strings.ReplaceAll(string(types.InternalEndpoint_s3upload), "{provider}", request.Provider)
 	var queries []string
-	if len(parameter.Directory) > 0 {
-		queries = append(queries, fmt.Sprintf("directory=%s", parameter.Directory))
+	if len(request.Directory) > 0 {
+		queries = append(queries, fmt.Sprintf("directory=%s", request.Directory))
 	}
-	if parameter.KeepOriginName {
+	if request.KeepOriginName {
 		queries = append(queries, "keepOriginName")
 	}
 	if len(queries) > 0 {
 		uploadPath += "?" + strings.Join(queries, "&")
 	}
-	req, err := http.NewRequest("POST", uploadPath, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadPath, body)
 	if err != nil {
 		return
 	}
 
+	req.ContentLength = contentLength
 	req.Header.Add("Content-Type", contentType)
-	if len(parameter.Profile) > 0 {
-		req.Header.Add(string(types.InternalHeader_X_Upload_Profile), string(parameter.Profile))
+	if request.Profile != "" {
+		req.Header.Add(string(types.InternalHeader_X_Upload_Profile), request.Profile)
 	}
-	if parameter.Metadata != nil {
-		metadataBytes, _ := utils.MarshalWithoutEscapeHTML(parameter.Metadata)
+	if request.Metadata != nil {
+		metadataBytes, _ := utils.MarshalWithoutEscapeHTML(request.Metadata)
 		req.Header.Add(string(types.InternalHeader_X_Metadata), string(metadataBytes))
 	}
-	for k, v := range parameter.Headers {
+	for k, v := range request.Headers {
 		if req.Header.Get(k) == "" {
 			req.Header.Set(k, v)
 		}
 	}
 
-	resp, err := uploadHttpClient.Do(req)
+	resp, err := doWithCircuitBreaker(uploadHttpClient, req)
 	if err != nil {
 		return
 	}
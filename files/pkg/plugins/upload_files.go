@@ -0,0 +1,175 @@
+package plugins
+
+import (
+	"custom-go/pkg/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	uploadFilePlan struct {
+		// 结构体中可能包含上传文件的字段
+		fields []*uploadFileField
+	}
+	uploadFileField struct {
+		index  int
+		name   string
+		inline bool
+	}
+)
+
+var (
+	uploadFileType      = reflect.TypeOf(&types.UploadFile{})
+	uploadFileSliceType = reflect.TypeOf([]*types.UploadFile{})
+	uploadFilePlans     = &sync.Map{}
+	uploadFileTypes     = &sync.Map{}
+)
+
+// collectUploadFiles 查找入参中任意层级的上传文件，键为以 . 分隔的 JSON 路径（数组下标为数字），
+// 与 OperationMultipartForm 的 fieldName 一致，[]*types.UploadFile 整体作为一个数组字段
+func collectUploadFiles(input any) fileFormData {
+	formData := make(fileFormData)
+	value := reflect.ValueOf(input)
+	if value.IsValid() && mayContainUploadFile(value.Type()) {
+		walkUploadFiles(value, nil, formData)
+	}
+	return formData
+}
+
+func walkUploadFiles(value reflect.Value, path []string, formData fileFormData) {
+	if !value.IsValid() {
+		return
+	}
+
+	switch value.Type() {
+	case uploadFileType:
+		if !value.IsNil() {
+			formData[strings.Join(path, ".")] = []*types.UploadFile{value.Interface().(*types.UploadFile)}
+		}
+		return
+	case uploadFileSliceType:
+		var files []*types.UploadFile
+		for _, item := range value.Interface().([]*types.UploadFile) {
+			if item != nil {
+				files = append(files, item)
+			}
+		}
+		if len(files) > 0 {
+			formData[strings.Join(path, ".")] = files
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			walkUploadFiles(value.Elem(), path, formData)
+		}
+	case reflect.Struct:
+		for _, field := range fetchUploadFilePlan(value.Type()).fields {
+			fieldPath := path
+			if !field.inline {
+				fieldPath = appendItem(path, field.name)
+			}
+			walkUploadFiles(value.Field(field.index), fieldPath, formData)
+		}
+	case reflect.Slice, reflect.Array:
+		if !mayContainUploadFile(value.Type().Elem()) {
+			return
+		}
+		for i := 0; i < value.Len(); i++ {
+			walkUploadFiles(value.Index(i), appendItem(path, strconv.Itoa(i)), formData)
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String || !mayContainUploadFile(value.Type().Elem()) {
+			return
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			walkUploadFiles(iter.Value(), appendItem(path, iter.Key().String()), formData)
+		}
+	}
+}
+
+// mayContainUploadFile 接口类型需运行时判断，视为可能包含，结果按类型缓存
+func mayContainUploadFile(t reflect.Type) bool {
+	if value, ok := uploadFileTypes.Load(t); ok {
+		return value.(bool)
+	}
+	contained := typeMayContainUploadFile(t, make(map[reflect.Type]bool))
+	uploadFileTypes.Store(t, contained)
+	return contained
+}
+
+func typeMayContainUploadFile(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t == uploadFileType || t == uploadFileSliceType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return typeMayContainUploadFile(t.Elem(), visiting)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && typeMayContainUploadFile(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return false
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return len(buildUploadFilePlan(t, visiting).fields) > 0
+	}
+	return false
+}
+
+func fetchUploadFilePlan(t reflect.Type) *uploadFilePlan {
+	if value, ok := uploadFilePlans.Load(t); ok {
+		return value.(*uploadFilePlan)
+	}
+	plan := buildUploadFilePlan(t, map[reflect.Type]bool{t: true})
+	uploadFilePlans.Store(t, plan)
+	return plan
+}
+
+func buildUploadFilePlan(t reflect.Type, visiting map[reflect.Type]bool) *uploadFilePlan {
+	if value, ok := uploadFilePlans.Load(t); ok {
+		return value.(*uploadFilePlan)
+	}
+
+	plan := &uploadFilePlan{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// 与 encoding/json 一致，非导出的嵌入结构体仍展开其导出字段
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		jsonTag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(jsonTag, ",")
+		if name == "-" {
+			continue
+		}
+		// 递归类型中正在解析的结构体视为可能包含
+		if !visiting[baseStructType(field.Type)] && !typeMayContainUploadFile(field.Type, visiting) {
+			continue
+		}
+		inline := field.Anonymous && name == "" && baseStructType(field.Type) != nil
+		if name == "" {
+			name = field.Name
+		}
+		plan.fields = append(plan.fields, &uploadFileField{index: i, name: name, inline: inline})
+	}
+	return plan
+}
+
+func baseStructType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
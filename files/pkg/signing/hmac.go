@@ -0,0 +1,107 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"custom-go/pkg/types"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HmacOptions 待签名字符串依次为（以换行分隔）：大写 Method、路径及查询参数、时间戳、
+// SignedHeaders 中各请求头的 "小写名称:值"、OriginBody 的 sha256 十六进制摘要
+type HmacOptions struct {
+	// sha256（默认）、sha1 或 sha512
+	Algorithm string `json:"algorithm,omitempty"`
+	// hex（默认）或 base64
+	Encoding string `json:"encoding,omitempty"`
+	// 写入签名的请求头，默认 X-Signature
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+	// 写入 accessKey 的请求头，默认 X-Access-Key，未配置 accessKey 时不写入
+	AccessKeyHeader string `json:"accessKeyHeader,omitempty"`
+	// 写入 unix 秒级时间戳的请求头，默认 X-Timestamp
+	TimestampHeader string   `json:"timestampHeader,omitempty"`
+	SignedHeaders   []string `json:"signedHeaders,omitempty"`
+}
+
+type hmacSigner struct {
+	config  *Config
+	options HmacOptions
+	hash    func() hash.Hash
+	now     func() time.Time
+}
+
+func newHmacSigner(config *Config) (signer *hmacSigner, err error) {
+	signer = &hmacSigner{config: config, now: time.Now}
+	if config.Hmac != nil {
+		signer.options = *config.Hmac
+	}
+	switch strings.ToLower(signer.options.Algorithm) {
+	case "", "sha256":
+		signer.hash = sha256.New
+	case "sha1":
+		signer.hash = sha1.New
+	case "sha512":
+		signer.hash = sha512.New
+	default:
+		err = fmt.Errorf("unsupported hmac algorithm [%s]", signer.options.Algorithm)
+		return
+	}
+	switch strings.ToLower(signer.options.Encoding) {
+	case "", "hex", "base64":
+	default:
+		err = fmt.Errorf("unsupported hmac encoding [%s]", signer.options.Encoding)
+		return
+	}
+	if signer.options.SignatureHeader == "" {
+		signer.options.SignatureHeader = "X-Signature"
+	}
+	if signer.options.AccessKeyHeader == "" {
+		signer.options.AccessKeyHeader = "X-Access-Key"
+	}
+	if signer.options.TimestampHeader == "" {
+		signer.options.TimestampHeader = "X-Timestamp"
+	}
+	return
+}
+
+func (s *hmacSigner) Sign(req *types.WunderGraphRequest) (err error) {
+	requestUrl, err := url.Parse(req.RequestURI)
+	if err != nil {
+		return
+	}
+	secretKey := types.GetConfigurationVal(s.config.SecretKey)
+	if secretKey == "" {
+		err = fmt.Errorf("signing [%s] got empty credentials", Kind_hmac)
+		return
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	setHeader(req, s.options.TimestampHeader, timestamp)
+	if accessKey := types.GetConfigurationVal(s.config.AccessKey); accessKey != "" {
+		setHeader(req, s.options.AccessKeyHeader, accessKey)
+	}
+
+	lines := []string{strings.ToUpper(req.Method), requestUrl.RequestURI(), timestamp}
+	for _, name := range s.options.SignedHeaders {
+		lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(req.Headers.GetIgnoreCase(name)))
+	}
+	lines = append(lines, hexSHA256(req.OriginBody))
+
+	mac := hmac.New(s.hash, []byte(secretKey))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	if strings.EqualFold(s.options.Encoding, "base64") {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	setHeader(req, s.options.SignatureHeader, signature)
+	return
+}
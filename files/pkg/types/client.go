@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"math/rand"
+	"net/http"
 	"time"
 )
 
@@ -16,6 +17,31 @@ type (
 	InternalClient struct {
 		ExtraHeaders RequestHeaders
 		*BaseRequestBodyWg
+		// Context 当前钩子请求的上下文，内部调用未指定上下文时默认绑定该上下文
+		Context context.Context
+		// Executor 非空时替代默认的 HTTP 实现执行内部调用，用于单元测试、录制和回放
+		Executor OperationExecutor
+		// Policy 非空时覆盖默认的超时和重试策略，operation 单独配置的策略优先
+		Policy *RequestPolicy
+		// PartialData 为 true 时返回 errors 的同时返回部分数据
+		PartialData bool
+		// Impersonation 非空表示由 AsUser/AsService 派生，User 已替换为目标身份
+		Impersonation *Impersonation
+	}
+	RequestPolicy struct {
+		// 单次请求超时，为 0 时不限制，订阅不受此限制
+		Timeout time.Duration
+		// 最大重试次数（不含首次请求），订阅仅在建立连接时重试
+		MaxRetries int
+		// 首次重试前的退避时间，之后每次翻倍并加入随机抖动，不超过 MaxBackoff
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		// 默认仅重试 query 和 subscription，mutation 需显式开启
+		RetryMutation bool
+		// 可重试的状态码，为空时使用 429/502/503/504
+		RetryStatusCodes []int
+		// 非空时替代默认的可重试判断，resp 和 err 仅有一个非空
+		Retryable func(resp *http.Response, err error) bool
 	}
 )
 
@@ -35,6 +61,13 @@ func NewEmptyInternalClient() *InternalClient {
 	}
 }
 
+func (i *InternalClient) RequestContext() context.Context {
+	if i == nil || i.Context == nil {
+		return context.Background()
+	}
+	return i.Context
+}
+
 func (i *InternalClient) WithHeaders(headers RequestHeaders) *InternalClient {
 	if len(i.ExtraHeaders) == 0 {
 		i.ExtraHeaders = headers
@@ -46,6 +79,21 @@ func (i *InternalClient) WithHeaders(headers RequestHeaders) *InternalClient {
 	return i
 }
 
+func (i *InternalClient) WithPolicy(policy *RequestPolicy) *InternalClient {
+	i.Policy = policy
+	return i
+}
+
+func (i *InternalClient) WithExecutor(executor OperationExecutor) *InternalClient {
+	i.Executor = executor
+	return i
+}
+
+func (i *InternalClient) WithPartialData() *InternalClient {
+	i.PartialData = true
+	return i
+}
+
 func InternalClientFactoryCall(headers RequestHeaders, wg *BaseRequestBodyWg) *InternalClient {
 	client := &InternalClient{
 		BaseRequestBodyWg: wg,
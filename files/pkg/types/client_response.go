@@ -0,0 +1,66 @@
+package types
+
+import (
+	"net/http"
+)
+
+// ClientResponse 钩子或函数对最终返回给客户端的响应的修改，由节点合并到客户端响应
+type ClientResponse struct {
+	StatusCode int            `json:"statusCode,omitempty"`
+	Headers    RequestHeaders `json:"headers,omitempty"`
+	// Set-Cookie 头部的完整值
+	Cookies []string `json:"cookies,omitempty"`
+}
+
+func (r *BaseRequestContext) fetchClientResponse() *ClientResponse {
+	if r.clientResponse == nil {
+		r.clientResponse = &ClientResponse{Headers: RequestHeaders{}}
+	}
+	return r.clientResponse
+}
+
+func (r *BaseRequestContext) SetClientResponseHeader(key, value string) {
+	r.fetchClientResponse().Headers[http.CanonicalHeaderKey(key)] = value
+}
+
+func (r *BaseRequestContext) SetClientResponseCookie(cookie *http.Cookie) {
+	if value := cookie.String(); value != "" {
+		response := r.fetchClientResponse()
+		response.Cookies = append(response.Cookies, value)
+	}
+}
+
+// DeleteClientResponseCookie 通过过期时间让客户端删除 cookie
+func (r *BaseRequestContext) DeleteClientResponseCookie(name, path string) {
+	r.SetClientResponseCookie(&http.Cookie{Name: name, Path: path, MaxAge: -1})
+}
+
+func (r *BaseRequestContext) SetClientResponseStatusCode(statusCode int) {
+	r.fetchClientResponse().StatusCode = statusCode
+}
+
+// ClientResponse 返回当前请求中设置的客户端响应修改，未设置时返回 nil
+func (r *BaseRequestContext) ClientResponse() *ClientResponse {
+	return r.clientResponse
+}
+
+// Merge 合并 other 的修改，other 中的值优先
+func (r *ClientResponse) Merge(other *ClientResponse) *ClientResponse {
+	if other == nil {
+		return r
+	}
+	if r == nil {
+		return other
+	}
+	if other.StatusCode != 0 {
+		r.StatusCode = other.StatusCode
+	}
+	if r.Headers == nil {
+		r.Headers = RequestHeaders{}
+	}
+	for k, v := range other.Headers {
+		r.Headers[k] = v
+	}
+	r.Cookies = append(r.Cookies, other.Cookies...)
+	return r
+}
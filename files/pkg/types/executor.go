@@ -0,0 +1,38 @@
+package types
+
+import (
+	"context"
+)
+
+type (
+	// OperationRequest plugins.ExecuteGraphql 执行的任意文档 Path 为 plugins.GraphqlEndpointPath，Input 为 *plugins.GraphqlRequest
+	// 结束事务的通知 Path 为 plugins.TransactionEndpointPath，Input 为 *plugins.TransactionFinishRequest
+	OperationRequest struct {
+		Path  string
+		Type  OperationType
+		Input any
+	}
+	UploadRequest struct {
+		// 对应 S3UploadConfiguration 的 name
+		Provider       string
+		Profile        string
+		Directory      string
+		KeepOriginName bool
+		Metadata       any
+		Headers        RequestHeaders
+		Files          []*UploadFile
+	}
+	// SubscriptionEvent Data 为订阅推送的 data 数据，Err 非空时为订阅异常结束前的最后一个事件
+	SubscriptionEvent struct {
+		Data []byte
+		Err  error
+	}
+	// OperationExecutor 内部调用的执行方式，默认请求 Fireboom 节点，可通过 InternalClient.Executor 替换
+	OperationExecutor interface {
+		// Execute 返回与节点一致的响应体，即 {"data": ..., "errors": [...]}
+		Execute(ctx context.Context, client *InternalClient, request *OperationRequest) ([]byte, error)
+		// Subscribe 返回的通道依次推送事件，订阅结束或 ctx 结束时关闭
+		Subscribe(ctx context.Context, client *InternalClient, request *OperationRequest) (<-chan *SubscriptionEvent, error)
+		Upload(ctx context.Context, client *InternalClient, request *UploadRequest) (UploadedFiles, error)
+	}
+)
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"errors"
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+// ServiceUserProvider AsService 生成的服务身份的 provider
+const ServiceUserProvider = "service"
+
+// ErrImpersonationDenied 未配置允许策略或策略拒绝时返回，可通过 errors.Is 判断
+var ErrImpersonationDenied = errors.New("impersonation denied")
+
+// Impersonation 派生客户端的身份切换记录
+type Impersonation struct {
+	// 服务身份名称，AsUser 时为空
+	Service string `json:"service,omitempty"`
+	// 切换前的用户，后台任务等无用户时为空
+	From *User `json:"from,omitempty"`
+	To   *User `json:"to"`
+}
+
+var impersonationGuard func(*InternalClient, *Impersonation) error
+
+// ImpersonationStrippedHeaders 派生客户端不转发的原始请求头，避免原调用者的凭证随切换后的身份发往上游
+var ImpersonationStrippedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// SetImpersonationGuard 设置身份切换的校验函数，返回错误时拒绝，未设置时拒绝所有切换
+func SetImpersonationGuard(guard func(*InternalClient, *Impersonation) error) {
+	impersonationGuard = guard
+}
+
+// AsUser 派生以 user 身份执行内部调用的客户端，原客户端不受影响
+func (i *InternalClient) AsUser(user *User) (*InternalClient, error) {
+	return i.impersonate(&Impersonation{To: user})
+}
+
+// AsService 派生以服务身份执行内部调用的客户端，userId 和 name 均为服务名称
+func (i *InternalClient) AsService(name string, roles []string) (*InternalClient, error) {
+	if name == "" {
+		return nil, errors.New("service name is empty")
+	}
+	return i.impersonate(&Impersonation{
+		Service: name,
+		To:      &User{UserId: name, Name: name, Provider: ServiceUserProvider, Roles: roles},
+	})
+}
+
+func (i *InternalClient) impersonate(impersonation *Impersonation) (*InternalClient, error) {
+	if impersonation.To == nil {
+		return nil, errors.New("impersonated user is nil")
+	}
+	if i == nil {
+		i = NewEmptyInternalClient()
+	}
+	clientRequest := &WunderGraphRequest{Headers: RequestHeaders{}}
+	if i.BaseRequestBodyWg != nil {
+		impersonation.From = i.User
+		if i.ClientRequest != nil {
+			clientRequest = stripCredentialHeaders(i.ClientRequest)
+		}
+	}
+	if impersonationGuard == nil {
+		return nil, ErrImpersonationDenied
+	}
+	if err := impersonationGuard(i, impersonation); err != nil {
+		return nil, err
+	}
+
+	derived := *i
+	derived.ExtraHeaders = make(RequestHeaders, len(i.ExtraHeaders))
+	for k, v := range i.ExtraHeaders {
+		derived.ExtraHeaders[k] = v
+	}
+	derived.BaseRequestBodyWg = &BaseRequestBodyWg{ClientRequest: clientRequest, User: impersonation.To}
+	derived.Impersonation = impersonation
+	return &derived, nil
+}
+
+func stripCredentialHeaders(request *WunderGraphRequest) *WunderGraphRequest {
+	stripped := *request
+	stripped.Headers = make(RequestHeaders, len(request.Headers))
+	for k, v := range request.Headers {
+		if !slices.ContainsFunc(ImpersonationStrippedHeaders, func(item string) bool { return strings.EqualFold(item, k) }) {
+			stripped.Headers[k] = v
+		}
+	}
+	return &stripped
+}
@@ -11,6 +11,12 @@ import (
 type UploadFile struct {
 	Reader io.Reader
 	Name   string
+	// 为空时根据文件扩展名推断，无法推断时为 application/octet-stream
+	ContentType string
+	// 文件大小，小于等于 0 时尝试从 Reader 获取，全部已知时请求携带 Content-Length
+	Size int64
+	// 每次写出后回调已写出的字节数，total 未知时为 -1
+	Progress func(written, total int64) `json:"-"`
 }
 
 const (
@@ -8,6 +8,7 @@ type (
 		Input                   I                         `json:"input,omitempty"`
 		Response                *OperationBodyResponse[O] `json:"response"`
 		SetClientRequestHeaders RequestHeaders            `json:"setClientRequestHeaders,omitempty"`
+		SetClientResponse       *ClientResponse           `json:"setClientResponse,omitempty"`
 	}
 	OperationBodyResponse[O any] struct {
 		DataAny any            `json:"dataAny,omitempty"`
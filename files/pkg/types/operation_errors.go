@@ -0,0 +1,98 @@
+package types
+
+import (
+	"encoding/json"
+	"github.com/spf13/cast"
+	"strings"
+)
+
+type (
+	// OperationError 节点返回的单个 GraphQL 错误，path 中数组下标为数字
+	OperationError struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path,omitempty"`
+		Locations  []*Location    `json:"locations,omitempty"`
+		Extensions map[string]any `json:"extensions,omitempty"`
+	}
+	// OperationErrors 内部调用返回 errors 时的错误，可通过 errors.As 获取
+	OperationErrors struct {
+		Operation string
+		Errors    []*OperationError
+		// 与 errors 一同返回的部分数据，可能为空
+		Data json.RawMessage
+	}
+)
+
+func (e *OperationError) Code() string {
+	return cast.ToString(e.Extensions["code"])
+}
+
+func (e *OperationError) PathString() string {
+	items := make([]string, 0, len(e.Path))
+	for _, item := range e.Path {
+		items = append(items, cast.ToString(item))
+	}
+	return strings.Join(items, ".")
+}
+
+func (e *OperationError) RequestError() RequestError {
+	path := make([]string, 0, len(e.Path))
+	for _, item := range e.Path {
+		path = append(path, cast.ToString(item))
+	}
+	return RequestError{Message: e.Message, Locations: e.Locations, Path: path}
+}
+
+func (e *OperationErrors) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, item := range e.Errors {
+		messages = append(messages, item.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HasCode 判断是否存在 extensions.code 为 code 的错误
+func (e *OperationErrors) HasCode(code string) bool {
+	for _, item := range e.Errors {
+		if item.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPath 判断是否存在路径以 path 开头的错误，数组下标以字符串形式传入
+func (e *OperationErrors) HasPath(path ...string) bool {
+	for _, item := range e.Errors {
+		if len(item.Path) < len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range path {
+			if cast.ToString(item.Path[i]) != segment {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *OperationErrors) RequestErrors() []RequestError {
+	result := make([]RequestError, 0, len(e.Errors))
+	for _, item := range e.Errors {
+		result = append(result, item.RequestError())
+	}
+	return result
+}
+
+// UnmarshalData 将部分数据反序列化到 v，无数据时不做处理
+func (e *OperationErrors) UnmarshalData(v any) error {
+	if len(e.Data) == 0 || string(e.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
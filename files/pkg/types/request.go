@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 )
 
@@ -28,6 +30,76 @@ func (r *WunderGraphRequest) NewRequest() *http.Request {
 	return req
 }
 
+// GetIgnoreCase 按 HTTP 头部规则忽略大小写获取值
+func (h RequestHeaders) GetIgnoreCase(key string) string {
+	if value, ok := h[key]; ok {
+		return value
+	}
+	if value, ok := h[http.CanonicalHeaderKey(key)]; ok {
+		return value
+	}
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (r *WunderGraphRequest) Cookies() []*http.Cookie {
+	if r == nil {
+		return nil
+	}
+	header := http.Header{}
+	if cookie := r.Headers.GetIgnoreCase(echo.HeaderCookie); cookie != "" {
+		header.Set(echo.HeaderCookie, cookie)
+	}
+	return (&http.Request{Header: header}).Cookies()
+}
+
+func (r *WunderGraphRequest) Cookie(name string) (*http.Cookie, error) {
+	for _, cookie := range r.Cookies() {
+		if cookie.Name == name {
+			return cookie, nil
+		}
+	}
+	return nil, http.ErrNoCookie
+}
+
+func (r *WunderGraphRequest) QueryParams() url.Values {
+	if r == nil {
+		return url.Values{}
+	}
+	requestUrl, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return url.Values{}
+	}
+	return requestUrl.Query()
+}
+
+func (r *WunderGraphRequest) QueryParam(name string) string {
+	return r.QueryParams().Get(name)
+}
+
+// ClientIP 依次读取 X-Forwarded-For、X-Real-Ip，均不存在时返回空
+func (r *WunderGraphRequest) ClientIP() string {
+	if r == nil {
+		return ""
+	}
+	if forwardedFor := r.Headers.GetIgnoreCase(echo.HeaderXForwardedFor); forwardedFor != "" {
+		ip, _, _ := strings.Cut(forwardedFor, ",")
+		return strings.TrimSpace(ip)
+	}
+	return strings.TrimSpace(r.Headers.GetIgnoreCase(echo.HeaderXRealIP))
+}
+
+func (r *WunderGraphRequest) UserAgent() string {
+	if r == nil {
+		return ""
+	}
+	return r.Headers.GetIgnoreCase("User-Agent")
+}
+
 func (r *WunderGraphResponse) Header() http.Header {
 	return make(http.Header)
 }
@@ -46,9 +118,15 @@ type (
 		HealthReport
 		sync.Mutex
 	}
+	// HealthWithStates 在健康检查中附带各组件的运行状态
+	HealthWithStates struct {
+		Health
+		States map[string]any `json:"states,omitempty"`
+	}
 	BaseRequestContext struct {
 		echo.Context
 		*InternalClient
+		clientResponse *ClientResponse
 	}
 	AuthenticationHookRequest = BaseRequestContext
 	HookRequest               = BaseRequestContext
@@ -62,6 +140,7 @@ type (
 	registeredHookWithClient func(echo.Logger, *InternalClient)
 	healthFunc               func(*echo.Echo, *HealthReportLock)
 	routerFunc               func(e *echo.Echo)
+	healthStateFunc          func() any
 )
 
 var (
@@ -69,6 +148,7 @@ var (
 	registeredHookWithClientArr []registeredHookWithClient
 	healthFuncArr               []healthFunc
 	routerFuncArr               []routerFunc
+	healthStateFuncMap          = make(map[string]healthStateFunc)
 )
 
 func GetRegisteredHookWithClientArr() []registeredHookWithClient {
@@ -102,3 +182,19 @@ func AddHealthFunc(f healthFunc) {
 func AddEchoRouterFunc(f routerFunc) {
 	routerFuncArr = append(routerFuncArr, f)
 }
+
+// AddHealthStateFunc 注册的状态在每次健康检查时以 name 为键输出
+func AddHealthStateFunc(name string, f healthStateFunc) {
+	healthStateFuncMap[name] = f
+}
+
+func GetHealthStates() map[string]any {
+	if len(healthStateFuncMap) == 0 {
+		return nil
+	}
+	states := make(map[string]any, len(healthStateFuncMap))
+	for name, f := range healthStateFuncMap {
+		states[name] = f()
+	}
+	return states
+}
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 type fileCache struct {
@@ -57,6 +58,46 @@ func ReadStructAndCacheFile(path string, result interface{}) error {
 	return json.Unmarshal(bytesData, &result)
 }
 
+type parsedFileCache struct {
+	modTime time.Time
+	size    int64
+	value   any
+}
+
+type parsedFileCacheKey struct {
+	path  string
+	rtype reflect.Type
+}
+
+var parsedFileCacheMap = &sync.Map{}
+
+// ParseAndCacheFile 文件修改时间或大小变化时才重新读取并调用 parse，否则返回缓存的解析结果
+func ParseAndCacheFile[T any](path string, parse func([]byte) (T, error)) (result T, err error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	key := parsedFileCacheKey{path: path, rtype: reflect.TypeOf((*T)(nil)).Elem()}
+	if value, ok := parsedFileCacheMap.Load(key); ok {
+		cache := value.(*parsedFileCache)
+		if cache.modTime.Equal(fileInfo.ModTime()) && cache.size == fileInfo.Size() {
+			result = cache.value.(T)
+			return
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if result, err = parse(content); err != nil {
+		return
+	}
+	parsedFileCacheMap.Store(key, &parsedFileCache{modTime: fileInfo.ModTime(), size: fileInfo.Size(), value: result})
+	return
+}
+
 func GetCallerName(prefix string) string {
 	if !strings.HasSuffix(prefix, "/") {
 		prefix += "/"
@@ -70,3 +70,38 @@ func MarshalWithoutEscapeHTML(obj any) ([]byte, error) {
 	err := jsonEncoder.Encode(obj)
 	return buffer.Bytes(), err
 }
+
+// RedactJson 将任意层级中字段名（忽略大小写）在 fields 内的值替换为 replacement
+func RedactJson(data []byte, fields []string, replacement any) []byte {
+	if len(data) == 0 || len(fields) == 0 {
+		return data
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return data
+	}
+	redacted, err := MarshalWithoutEscapeHTML(redactValue(value, fields, replacement))
+	if err != nil {
+		return data
+	}
+	return bytes.TrimSpace(redacted)
+}
+
+func redactValue(value any, fields []string, replacement any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if slices.ContainsFunc(fields, func(field string) bool { return strings.EqualFold(field, key) }) {
+				v[key] = replacement
+				continue
+			}
+			v[key] = redactValue(item, fields, replacement)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item, fields, replacement)
+		}
+	}
+	return value
+}
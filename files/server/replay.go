@@ -0,0 +1,38 @@
+package server
+
+import (
+	"custom-go/pkg/plugins"
+	"fmt"
+)
+
+const replayCommand = "replay"
+
+// replayRecordings 例如 go run main.go replay recordings/xxx.json，存在差异时返回错误
+func replayRecordings(files []string) error {
+	wdgServer := configureWunderGraphServer()
+	var failed int
+	for _, file := range files {
+		recording, err := plugins.LoadHookRecording(file)
+		if err != nil {
+			fmt.Printf("[FAIL] %s: %v\n", file, err)
+			failed++
+			continue
+		}
+
+		diffs := plugins.ReplayHookRecording(wdgServer, recording)
+		if len(diffs) == 0 {
+			fmt.Printf("[PASS] %s %s\n", recording.Method, recording.Path)
+			continue
+		}
+
+		failed++
+		fmt.Printf("[FAIL] %s %s\n", recording.Method, recording.Path)
+		for _, diff := range diffs {
+			fmt.Printf("    %s\n", diff)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d recording(s) replayed with differences", failed)
+	}
+	return nil
+}
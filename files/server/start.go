@@ -21,6 +21,13 @@ import (
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	if len(os.Args) > 2 && os.Args[1] == replayCommand {
+		if err := replayRecordings(os.Args[2:]); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := startServer(); err != nil {
 		os.Exit(1)
 	}
@@ -52,17 +59,20 @@ func configureWunderGraphServer() *echo.Echo {
 	registerOnce := &sync.Once{}
 	e.Use(middleware.Recover(), func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			registerOnce.Do(func() {
-				for _, registeredHook := range types.GetRegisteredHookArr() {
-					go registeredHook(e.Logger)
-				}
-				if registeredHooks := types.GetRegisteredHookWithClientArr(); len(registeredHooks) > 0 {
-					client := types.NewEmptyInternalClient()
-					for _, registeredHook := range registeredHooks {
-						go registeredHook(e.Logger, client)
+			// 回放录制时不启动后台任务，避免访问真实节点
+			if !plugins.ReplayingRequest(c.Request()) {
+				registerOnce.Do(func() {
+					for _, registeredHook := range types.GetRegisteredHookArr() {
+						go registeredHook(e.Logger)
 					}
-				}
-			})
+					if registeredHooks := types.GetRegisteredHookWithClientArr(); len(registeredHooks) > 0 {
+						client := types.NewEmptyInternalClient()
+						for _, registeredHook := range registeredHooks {
+							go registeredHook(e.Logger, client)
+						}
+					}
+				})
+			}
 			if c.Request().Method == http.MethodGet {
 				return next(c)
 			}
@@ -89,13 +99,14 @@ func configureWunderGraphServer() *echo.Echo {
 				headerRequestIdKey: c.Request().Header.Get(headerRequestIdKey),
 				headerTraceIdKey:   c.Request().Header.Get(headerTraceIdKey),
 			}, body.Wg)
+			internalClient.Context = c.Request().Context()
 			brc := &types.BaseRequestContext{
 				Context:        c,
 				InternalClient: internalClient,
 			}
 			return next(brc)
 		}
-	})
+	}, plugins.RecordHookMiddleware)
 
 	for _, routerFunc := range types.GetEchoRouterFuncArr() {
 		routerFunc(e)
@@ -113,10 +124,13 @@ func configureWunderGraphServer() *echo.Echo {
 	workdir, _ := os.Getwd()
 	// 健康检查
 	e.GET(string(types.Endpoint_health), func(c echo.Context) error {
-		return c.JSON(http.StatusOK, types.Health{
-			Status:  "ok",
-			Report:  &healthReport.HealthReport,
-			Workdir: workdir,
+		return c.JSON(http.StatusOK, types.HealthWithStates{
+			Health: types.Health{
+				Status:  "ok",
+				Report:  &healthReport.HealthReport,
+				Workdir: workdir,
+			},
+			States: types.GetHealthStates(),
 		})
 	})
 
@@ -124,6 +138,11 @@ func configureWunderGraphServer() *echo.Echo {
 }
 
 func startServer() error {
+	if err := plugins.CheckHookRegistrations(); err != nil {
+		log.Errorf("register hooks failed, err: %v", err.Error())
+		return err
+	}
+
 	graphqlApi := types.WdgGraphConfig.Api
 	types.PublicNodeUrl = types.GetConfigurationVal(graphqlApi.NodeOptions.PublicNodeUrl)
 	types.PrivateNodeUrl = types.GetConfigurationVal(graphqlApi.NodeOptions.NodeUrl)
@@ -141,7 +160,7 @@ func startServer() error {
 	}()
 
 	// 等待终止信号
-	stop := make(chan os.Signal)
+	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 